@@ -1,10 +1,14 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
+	"fmt"
 	"log/slog"
 	"os"
+	"strings"
 
+	"github.com/lcrownover/duckpaste/internal/audit"
 	"github.com/lcrownover/duckpaste/internal/db"
 	"github.com/lcrownover/duckpaste/internal/web"
 )
@@ -39,6 +43,28 @@ func main() {
 	}
 	cosmosHandler.Init()
 
+	auditConfig, err := audit.GetAuditConfig()
+	if err != nil {
+		slog.Error("Failed to get Audit Config", "error", err)
+		os.Exit(1)
+	}
+	auditSink, err := audit.NewSink(auditConfig, cosmosHandler)
+	if err != nil {
+		slog.Error("Failed to create Audit Sink", "error", err)
+		os.Exit(1)
+	}
+	audit.SetDefault(audit.NewRecorder(auditSink))
+
+	// Admin commands
+	switch flag.Arg(0) {
+	case "audit":
+		os.Exit(runAudit(flag.Arg(1)))
+	case "delete":
+		os.Exit(runDelete(cosmosHandler, flag.Arg(1)))
+	case "list":
+		os.Exit(runList(cosmosHandler, flag.Args()[1:]))
+	}
+
 	// // Test payload
 	// content := "pretend-im-a-paste"
 	// item := cosmosHandler.NewItem(content, 24, false)
@@ -65,7 +91,86 @@ func main() {
 	// 	os.Exit(1)
 	// }
 
-	go db.StartCleaner(cosmosHandler, db.NewCleanerOpts(1))
+	cleanerOpts := db.NewCleanerOpts(1)
+	cleanerOpts.OnExpire = func(id db.ItemID, err error) {
+		outcome, detail := audit.OutcomeSuccess, ""
+		if err != nil {
+			outcome, detail = audit.OutcomeFailure, err.Error()
+		}
+		audit.Record(audit.Event{
+			Action:  audit.ActionExpire,
+			PasteID: string(id),
+			User:    "cleaner",
+			Outcome: outcome,
+			Detail:  detail,
+		})
+	}
+	go db.StartCleaner(cosmosHandler, cleanerOpts)
 
 	web.StartServer()
 }
+
+// runAudit prints every audit event for the paste as JSON lines
+func runAudit(pasteID string) int {
+	if pasteID == "" {
+		fmt.Fprintln(os.Stderr, "usage: duckpaste audit <pasteId>")
+		return 2
+	}
+	events, err := audit.Default().Query(pasteID)
+	if err != nil {
+		slog.Error("Failed to query audit log", "error", err)
+		return 1
+	}
+	enc := json.NewEncoder(os.Stdout)
+	for _, e := range events {
+		enc.Encode(e)
+	}
+	return 0
+}
+
+// runDelete removes a paste by hand, e.g. when it contains something that
+// shouldn't have been shared
+func runDelete(h *db.CosmosHandler, pasteID string) int {
+	if pasteID == "" {
+		fmt.Fprintln(os.Stderr, "usage: duckpaste delete <pasteId>")
+		return 2
+	}
+	user := os.Getenv("USER")
+	err := h.DeleteItem(db.ItemID(pasteID))
+	if err != nil {
+		audit.Record(audit.Event{Action: audit.ActionDelete, PasteID: pasteID, User: user, Outcome: audit.OutcomeFailure, Detail: err.Error()})
+		slog.Error("Failed to delete paste", "error", err)
+		return 1
+	}
+	audit.Record(audit.Event{Action: audit.ActionDelete, PasteID: pasteID, User: user, Outcome: audit.OutcomeSuccess})
+	return 0
+}
+
+// runList prints the metadata of every paste as JSON lines, optionally only
+// those of one API client or with one tag
+func runList(h *db.CosmosHandler, args []string) int {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	owner := fs.String("owner", "", "only list pastes created with this API client's key")
+	tag := fs.String("tag", "", "only list pastes with this tag")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: duckpaste list [-owner name] [-tag tag]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	items, err := h.ListItems(*owner, strings.ToLower(*tag))
+	if err != nil {
+		slog.Error("Failed to list pastes", "error", err)
+		return 1
+	}
+	enc := json.NewEncoder(os.Stdout)
+	for _, item := range items {
+		summary := web.NewPasteSummary(item)
+		enc.Encode(struct {
+			web.PasteSummary
+			Owner string `json:"owner"`
+		}{summary, item.Owner})
+	}
+	return 0
+}
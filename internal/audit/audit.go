@@ -0,0 +1,104 @@
+package audit
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// The audit log records what happened to a paste over its lifetime, and who
+// caused it. It's separate from the regular slog output so it can be kept
+// longer and queried by paste ID during incident response.
+
+type Action string
+
+const (
+	ActionCreate Action = "create"
+	ActionRead   Action = "read"
+	ActionBurn   Action = "burn"
+	ActionExpire Action = "expire"
+	ActionDelete Action = "delete"
+	ActionEdit   Action = "edit"
+)
+
+type Outcome string
+
+const (
+	OutcomeSuccess Outcome = "success"
+	OutcomeFailure Outcome = "failure"
+	OutcomeDenied  Outcome = "denied"
+)
+
+type Event struct {
+	Id        string    `json:"id"`
+	Partition string    `json:"partition,omitempty"`
+	Time      time.Time `json:"time"`
+	Action    Action    `json:"action"`
+	PasteID   string    `json:"pasteId"`
+	ClientIP  string    `json:"clientIp,omitempty"`
+	User      string    `json:"user,omitempty"`
+	UserAgent string    `json:"userAgent,omitempty"`
+	Outcome   Outcome   `json:"outcome"`
+	Detail    string    `json:"detail,omitempty"`
+}
+
+// Sink is where audit events end up.
+type Sink interface {
+	Write(e Event) error
+	Query(pasteID string) ([]Event, error)
+}
+
+type Recorder struct {
+	sink Sink
+}
+
+func NewRecorder(sink Sink) *Recorder {
+	return &Recorder{sink: sink}
+}
+
+// Record fills in the event time and writes the event to the sink.
+// Failing to write an audit event is logged but never fails the request
+// that caused it.
+func (r *Recorder) Record(e Event) {
+	if r == nil || r.sink == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	err := r.sink.Write(e)
+	if err != nil {
+		slog.Error("failed to write audit event: "+err.Error(), "action", string(e.Action), "id", e.PasteID, "source", "Record")
+	}
+}
+
+func (r *Recorder) Query(pasteID string) ([]Event, error) {
+	if r == nil || r.sink == nil {
+		return nil, fmt.Errorf("no audit sink configured")
+	}
+	return r.sink.Query(pasteID)
+}
+
+var (
+	defaultMu       sync.RWMutex
+	defaultRecorder *Recorder
+)
+
+// SetDefault makes r the recorder used by the package-level Record function.
+func SetDefault(r *Recorder) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultRecorder = r
+}
+
+func Default() *Recorder {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultRecorder
+}
+
+// Record writes e to the default recorder, if one is set.
+func Record(e Event) {
+	Default().Record(e)
+}
@@ -0,0 +1,73 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azcosmos"
+	"github.com/lcrownover/duckpaste/internal/db"
+)
+
+// CosmosSink stores events in their own container next to the pastes, so
+// they don't get swept up by the cleaner.
+type CosmosSink struct {
+	containerClient *azcosmos.ContainerClient
+	partition       string
+}
+
+func NewCosmosSink(h *db.CosmosHandler, containerName string) (*CosmosSink, error) {
+	containerClient, err := db.CreateContainer(h.Client, h.DatabaseName, containerName, "/partition")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create audit container: %v", err)
+	}
+	return &CosmosSink{
+		containerClient: containerClient,
+		partition:       h.Partition,
+	}, nil
+}
+
+func (s *CosmosSink) Write(e Event) error {
+	if e.Id == "" {
+		e.Id = fmt.Sprintf("%s-%s-%d", e.PasteID, e.Action, e.Time.UnixNano())
+	}
+	e.Partition = s.partition
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit event: %v", err)
+	}
+
+	pk := azcosmos.NewPartitionKeyString(s.partition)
+	_, err = s.containerClient.CreateItem(context.Background(), pk, b, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create audit item: %v", err)
+	}
+	return nil
+}
+
+func (s *CosmosSink) Query(pasteID string) ([]Event, error) {
+	pk := azcosmos.NewPartitionKeyString(s.partition)
+	opts := &azcosmos.QueryOptions{
+		QueryParameters: []azcosmos.QueryParameter{
+			{Name: "@pasteId", Value: pasteID},
+		},
+	}
+	queryPager := s.containerClient.NewQueryItemsPager("SELECT * FROM c WHERE c.pasteId = @pasteId ORDER BY c.time", pk, opts)
+
+	events := []Event{}
+	for queryPager.More() {
+		queryResponse, err := queryPager.NextPage(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get next page: %v", err)
+		}
+		for _, respItem := range queryResponse.Items {
+			var e Event
+			if err := json.Unmarshal(respItem, &e); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal audit event: %v", err)
+			}
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
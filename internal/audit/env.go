@@ -0,0 +1,59 @@
+package audit
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/lcrownover/duckpaste/internal/db"
+)
+
+const (
+	SinkNone   string = "none"
+	SinkFile   string = "file"
+	SinkCosmos string = "cosmos"
+)
+
+type AuditConfig struct {
+	Sink      string
+	File      string
+	Container string
+}
+
+func GetAuditConfig() (*AuditConfig, error) {
+	sink, found := os.LookupEnv("AUDIT_SINK")
+	if !found {
+		sink = SinkNone
+	}
+	file, found := os.LookupEnv("AUDIT_FILE")
+	if !found {
+		file = "audit.jsonl"
+	}
+	container, found := os.LookupEnv("AUDIT_CONTAINER")
+	if !found {
+		container = "audit"
+	}
+
+	switch sink {
+	case SinkNone, SinkFile, SinkCosmos:
+	default:
+		return nil, fmt.Errorf("AUDIT_SINK must be one of %q, %q or %q", SinkNone, SinkFile, SinkCosmos)
+	}
+
+	return &AuditConfig{
+		Sink:      sink,
+		File:      file,
+		Container: container,
+	}, nil
+}
+
+// NewSink builds the sink described by cfg. The cosmos handler is only used
+// for the cosmos sink and must already be initialized.
+func NewSink(cfg *AuditConfig, h *db.CosmosHandler) (Sink, error) {
+	switch cfg.Sink {
+	case SinkFile:
+		return NewFileSink(cfg.File)
+	case SinkCosmos:
+		return NewCosmosSink(h, cfg.Container)
+	}
+	return nil, nil
+}
@@ -0,0 +1,70 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// FileSink appends events to a file as JSON lines.
+type FileSink struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+func NewFileSink(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit file: %v", err)
+	}
+	return &FileSink{path: path, file: f}, nil
+}
+
+func (s *FileSink) Write(e Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit event: %v", err)
+	}
+	b = append(b, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.file.Write(b)
+	if err != nil {
+		return fmt.Errorf("failed to write audit event: %v", err)
+	}
+	return nil
+}
+
+func (s *FileSink) Query(pasteID string) ([]Event, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit file: %v", err)
+	}
+	defer f.Close()
+
+	events := []Event{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// skip lines we can't parse rather than failing the whole query
+			continue
+		}
+		if e.PasteID == pasteID {
+			events = append(events, e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit file: %v", err)
+	}
+	return events, nil
+}
+
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
@@ -0,0 +1,208 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/data/azcosmos"
+)
+
+// Cosmos documents are capped at 2MB. Items bigger than chunkThreshold once
+// serialized are split into chunk documents, and the item itself is stored
+// as a manifest: the item metadata without content, plus the chunk count
+// and a checksum of the whole serialized item.
+//
+// Chunk ids carry a generation taken from the checksum, so replacing an item
+// writes its new chunks next to the old ones, and the old ones are removed
+// only once the new manifest is stored. The view count lives on the manifest
+// alone; see CountView.
+
+const (
+	chunkThreshold int = 1536 << 10
+	// Chunk data is base64 encoded in the document, so leave room for that
+	chunkSize int = 1 << 20
+)
+
+type ChunkManifest struct {
+	Count  int    `json:"count"`
+	Size   int    `json:"size"`
+	SHA256 string `json:"sha256"`
+	// empty for items chunked before chunk ids had a generation
+	Generation string `json:"generation,omitempty"`
+}
+
+type itemChunk struct {
+	Id        string `json:"id"`
+	Partition string `json:"partition"`
+	ChunkOf   ItemID `json:"chunkOf"`
+	Index     int    `json:"index"`
+	Data      []byte `json:"data"`
+}
+
+func chunkID(itemID ItemID, manifest *ChunkManifest, index int) string {
+	if manifest.Generation == "" {
+		return fmt.Sprintf("%s-chunk-%d", itemID, index)
+	}
+	return fmt.Sprintf("%s-chunk-%s-%d", itemID, manifest.Generation, index)
+}
+
+// sameChunks reports whether two manifests point at the same chunk
+// documents.
+func sameChunks(a, b *ChunkManifest) bool {
+	return a != nil && b != nil && a.Generation != "" && a.Generation == b.Generation
+}
+
+// prepareItem serializes the item for storage, writing chunks first if it
+// needs them. It returns the document to store under the item's id and the
+// manifest of the chunks it wrote, if any. When writing a chunk fails the
+// chunks already written are removed again, unless they are also the chunks
+// of the stored item, old.
+func (h *CosmosHandler) prepareItem(ctx context.Context, containerClient *azcosmos.ContainerClient, item *Item, old *ChunkManifest) ([]byte, *ChunkManifest, error) {
+	stored := h.compressItem(item)
+	b, err := json.Marshal(stored)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(b) <= chunkThreshold {
+		return b, nil, nil
+	}
+
+	sum := sha256.Sum256(b)
+	manifest := *stored
+	manifest.Content = ""
+	manifest.ContentEncoding = EncodingNone
+	manifest.Files = nil
+	manifest.Attachments = nil
+	manifest.Revisions = nil
+	checksum := hex.EncodeToString(sum[:])
+	manifest.Chunks = &ChunkManifest{
+		Count:      (len(b) + chunkSize - 1) / chunkSize,
+		Size:       len(b),
+		SHA256:     checksum,
+		Generation: checksum[:16],
+	}
+
+	pk := azcosmos.NewPartitionKeyString(h.Partition)
+	for i := 0; i < manifest.Chunks.Count; i++ {
+		end := min((i+1)*chunkSize, len(b))
+		chunk, err := json.Marshal(itemChunk{
+			Id:        chunkID(item.Id, manifest.Chunks, i),
+			Partition: h.Partition,
+			ChunkOf:   item.Id,
+			Index:     i,
+			Data:      b[i*chunkSize : end],
+		})
+		if err == nil {
+			// upsert, since storing identical content again reuses the
+			// chunk ids
+			_, err = containerClient.UpsertItem(ctx, pk, chunk, nil)
+		}
+		if err != nil {
+			if !sameChunks(old, manifest.Chunks) {
+				h.discardChunks(ctx, containerClient, item.Id, &ChunkManifest{Count: i, Generation: manifest.Chunks.Generation})
+			}
+			return nil, nil, fmt.Errorf("failed to write chunk %d: %v", i, err)
+		}
+	}
+	slog.Debug("item chunked", "id", string(item.Id), "size", len(b), "chunks", manifest.Chunks.Count)
+
+	b, err = json.Marshal(manifest)
+	if err != nil {
+		if !sameChunks(old, manifest.Chunks) {
+			h.discardChunks(ctx, containerClient, item.Id, manifest.Chunks)
+		}
+		return nil, nil, err
+	}
+	return b, manifest.Chunks, nil
+}
+
+// assembleItem reads the chunks of a manifest back into the full item,
+// checking they add up to what was written.
+func (h *CosmosHandler) assembleItem(ctx context.Context, containerClient *azcosmos.ContainerClient, manifest *Item) (*Item, error) {
+	pk := azcosmos.NewPartitionKeyString(h.Partition)
+	b := make([]byte, 0, manifest.Chunks.Size)
+	for i := 0; i < manifest.Chunks.Count; i++ {
+		resp, err := containerClient.ReadItem(ctx, pk, chunkID(manifest.Id, manifest.Chunks, i), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read chunk %d: %v", i, err)
+		}
+		var chunk itemChunk
+		err = json.Unmarshal(resp.Value, &chunk)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal chunk %d: %v", i, err)
+		}
+		if chunk.ChunkOf != manifest.Id || chunk.Index != i {
+			return nil, fmt.Errorf("chunk %d doesn't belong to item", i)
+		}
+		b = append(b, chunk.Data...)
+	}
+
+	sum := sha256.Sum256(b)
+	if len(b) != manifest.Chunks.Size || hex.EncodeToString(sum[:]) != manifest.Chunks.SHA256 {
+		return nil, fmt.Errorf("chunks failed integrity check")
+	}
+
+	var item Item
+	err := json.Unmarshal(b, &item)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal chunked item: %v", err)
+	}
+	return &item, nil
+}
+
+// storedManifest reads the chunk manifest of the stored item, without its
+// chunks. It is nil when the item doesn't exist or isn't chunked.
+func (h *CosmosHandler) storedManifest(ctx context.Context, containerClient *azcosmos.ContainerClient, itemID ItemID) *ChunkManifest {
+	pk := azcosmos.NewPartitionKeyString(h.Partition)
+	resp, err := containerClient.ReadItem(ctx, pk, string(itemID), nil)
+	if err != nil {
+		return nil
+	}
+	var manifest struct {
+		Chunks *ChunkManifest `json:"chunks"`
+	}
+	err = json.Unmarshal(resp.Value, &manifest)
+	if err != nil {
+		return nil
+	}
+	return manifest.Chunks
+}
+
+// deleteChunkSet removes the chunks a manifest points at.
+func (h *CosmosHandler) deleteChunkSet(ctx context.Context, containerClient *azcosmos.ContainerClient, itemID ItemID, manifest *ChunkManifest) error {
+	pk := azcosmos.NewPartitionKeyString(h.Partition)
+	for i := 0; i < manifest.Count; i++ {
+		_, err := containerClient.DeleteItem(ctx, pk, chunkID(itemID, manifest, i), nil)
+		if err != nil {
+			return fmt.Errorf("failed to delete chunk %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// discardChunks removes chunks nothing points at anymore, logging failures
+// since the caller is already handling another error or has succeeded.
+func (h *CosmosHandler) discardChunks(ctx context.Context, containerClient *azcosmos.ContainerClient, itemID ItemID, manifest *ChunkManifest) {
+	if manifest == nil {
+		return
+	}
+	err := h.deleteChunkSet(ctx, containerClient, itemID, manifest)
+	if err != nil {
+		slog.Error("failed to discard chunks: "+err.Error(), "id", string(itemID), "source", "discardChunks")
+	}
+}
+
+// deleteChunks removes the chunks of an item, if it has any. It reads the
+// stored document rather than the assembled item so it works on manifests
+// whose chunks are damaged.
+func (h *CosmosHandler) deleteChunks(ctx context.Context, containerClient *azcosmos.ContainerClient, itemID ItemID) error {
+	manifest := h.storedManifest(ctx, containerClient, itemID)
+	if manifest == nil {
+		return nil
+	}
+	return h.deleteChunkSet(ctx, containerClient, itemID, manifest)
+}
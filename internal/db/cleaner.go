@@ -11,6 +11,8 @@ import (
 type CleanerOpts struct {
 	// How often to run the cleaner
 	Interval time.Duration
+	// Called after each expired item is deleted, with the result of the delete
+	OnExpire func(id ItemID, err error)
 }
 
 func NewCleanerOpts(intervalMinutes int) *CleanerOpts {
@@ -38,10 +40,16 @@ func StartCleaner(h *CosmosHandler, opts *CleanerOpts) {
 				slog.Error("failed to parse item time: "+err.Error(), "source", "StartCleaner")
 				goto sleep
 			}
-			itemExpirationTime := item.Created.Add(time.Duration(item.LifetimeHours) * time.Hour)
-			if time.Now().After(itemExpirationTime) {
+			itemExpirationTime, expires := item.ExpiresAt()
+			if expires && time.Now().After(itemExpirationTime) {
 				slog.Info("deleting expired item", "id", string(item.Id), "source", "StartCleaner")
-				h.DeleteItem(item.Id)
+				err := h.DeleteItem(item.Id)
+				if err != nil {
+					slog.Error("failed to delete expired item: "+err.Error(), "id", string(item.Id), "source", "StartCleaner")
+				}
+				if opts.OnExpire != nil {
+					opts.OnExpire(item.Id, err)
+				}
 			}
 		}
 	sleep:
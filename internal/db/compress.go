@@ -0,0 +1,144 @@
+package db
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// Content, files and revisions are compressed on their way into Cosmos and
+// decompressed on their way out, so everything outside this package only
+// ever sees plain base64 content. Items written before compression have no
+// encoding marker and are read as they are.
+
+const (
+	EncodingNone string = ""
+	EncodingGzip string = "gzip"
+)
+
+// compressContent gzips base64 content, returning it re-encoded.
+func compressContent(content ItemContent) (ItemContent, error) {
+	raw, err := base64.StdEncoding.DecodeString(string(content))
+	if err != nil {
+		return content, fmt.Errorf("failed to decode content: %v", err)
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err = zw.Write(raw)
+	if err == nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		return content, fmt.Errorf("failed to compress content: %v", err)
+	}
+	return ItemContent(base64.StdEncoding.EncodeToString(buf.Bytes())), nil
+}
+
+func decompressContent(content ItemContent) (ItemContent, error) {
+	raw, err := base64.StdEncoding.DecodeString(string(content))
+	if err != nil {
+		return content, fmt.Errorf("failed to decode content: %v", err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return content, fmt.Errorf("failed to decompress content: %v", err)
+	}
+	defer zr.Close()
+	decompressed, err := io.ReadAll(zr)
+	if err != nil {
+		return content, fmt.Errorf("failed to decompress content: %v", err)
+	}
+	return ItemContent(base64.StdEncoding.EncodeToString(decompressed)), nil
+}
+
+// compressItem returns a copy of the item ready to be stored. The content,
+// files and revisions are only stored compressed if that saves space
+// overall, tiny pastes can come out bigger.
+func (h *CosmosHandler) compressItem(item *Item) *Item {
+	if h.Compression != EncodingGzip || item.ContentEncoding != EncodingNone {
+		return item
+	}
+
+	stored := *item
+	stored.Files = append([]ItemFile{}, item.Files...)
+	stored.Revisions = append([]ItemRevision{}, item.Revisions...)
+
+	before, after := len(item.Content), 0
+	content, err := compressContent(item.Content)
+	if err != nil {
+		slog.Error("failed to compress item: "+err.Error(), "id", string(item.Id), "source", "compressItem")
+		return item
+	}
+	stored.Content = content
+	after += len(content)
+	for i, f := range stored.Files {
+		before += len(f.Content)
+		content, err := compressContent(f.Content)
+		if err != nil {
+			slog.Error("failed to compress item: "+err.Error(), "id", string(item.Id), "source", "compressItem")
+			return item
+		}
+		stored.Files[i].Content = content
+		after += len(content)
+	}
+	for i, r := range stored.Revisions {
+		before += len(r.Content)
+		content, err := compressContent(r.Content)
+		if err != nil {
+			slog.Error("failed to compress item: "+err.Error(), "id", string(item.Id), "source", "compressItem")
+			return item
+		}
+		stored.Revisions[i].Content = content
+		after += len(content)
+	}
+
+	if after >= before {
+		slog.Debug("not compressing item", "id", string(item.Id), "before", before, "after", after, "source", "compressItem")
+		return item
+	}
+	stored.ContentEncoding = EncodingGzip
+
+	ratio := 0.0
+	if before > 0 {
+		ratio = float64(after) / float64(before)
+	}
+	slog.Debug("compressed item", "id", string(item.Id), "before", before, "after", after, "ratio", fmt.Sprintf("%.2f", ratio), "source", "compressItem")
+	return &stored
+}
+
+// decompressItem restores the item content in place after reading it.
+func decompressItem(item *Item) error {
+	switch item.ContentEncoding {
+	case EncodingNone:
+		return nil
+	case EncodingGzip:
+	default:
+		return fmt.Errorf("unknown content encoding: %s", item.ContentEncoding)
+	}
+
+	content, err := decompressContent(item.Content)
+	if err != nil {
+		return err
+	}
+	item.Content = content
+	for i, f := range item.Files {
+		content, err := decompressContent(f.Content)
+		if err != nil {
+			return err
+		}
+		item.Files[i].Content = content
+	}
+	for i, r := range item.Revisions {
+		content, err := decompressContent(r.Content)
+		if err != nil {
+			return err
+		}
+		item.Revisions[i].Content = content
+	}
+	item.ContentEncoding = EncodingNone
+	return nil
+}
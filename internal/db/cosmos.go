@@ -4,9 +4,11 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"math/rand"
+	"net/http"
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
@@ -24,6 +26,8 @@ type CosmosHandler struct {
 	DatabaseName  string
 	ContainerName string
 	Partition     string
+	// How content is compressed when stored
+	Compression string
 }
 
 func NewCosmosHandler(cfg *CosmosConfig) (*CosmosHandler, error) {
@@ -40,6 +44,7 @@ func NewCosmosHandler(cfg *CosmosConfig) (*CosmosHandler, error) {
 		DatabaseName:    cfg.DatabaseName,
 		ContainerName:   cfg.ContainerName,
 		Partition:       cfg.Partition,
+		Compression:     cfg.Compression,
 	}, nil
 }
 
@@ -67,6 +72,115 @@ type Item struct {
 	Password      ItemContent `json:"password"`
 	DeleteOnRead  bool        `json:"deleteOnRead"`
 	Created       time.Time   `json:"created"`
+
+	// When the item expires, unless it never does. Items from before this was
+	// stored expire LifetimeHours after they were created.
+	Expires      time.Time `json:"expires"`
+	NeverExpires bool      `json:"neverExpires,omitempty"`
+
+	// How Content and Files are stored, see compress.go
+	ContentEncoding string `json:"contentEncoding,omitempty"`
+
+	// Set when the item is stored in chunks, see chunks.go
+	Chunks *ChunkManifest `json:"chunks,omitempty"`
+
+	// Only clients in these networks may read the item, if set
+	AllowedNetworks []string `json:"allowedNetworks,omitempty"`
+
+	// How the content is displayed
+	Language string `json:"language,omitempty"`
+	Format   string `json:"format,omitempty"`
+
+	// What kind of content it is, as detected when it was stored
+	ContentType string `json:"contentType,omitempty"`
+
+	// Burn the item after this many reads, if set
+	MaxViews int `json:"maxViews,omitempty"`
+	Views    int `json:"views,omitempty"`
+
+	// Files uploaded with the item
+	Attachments []Attachment `json:"attachments,omitempty"`
+
+	// Named text files, for pastes made of several files
+	Files []ItemFile `json:"files,omitempty"`
+
+	// Hash of the token that allows editing the content
+	EditToken string `json:"editToken,omitempty"`
+	// Earlier versions of the content, oldest first. The current content is
+	// the revision after the last of these.
+	Revisions []ItemRevision `json:"revisions,omitempty"`
+	Updated   time.Time      `json:"updated"`
+
+	// The item this one was forked from
+	ForkOf ItemID `json:"forkOf,omitempty"`
+
+	// Optional description of the item
+	Title       string   `json:"title,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+
+	// The content is a URL to redirect to, see links in the web package
+	ShortLink    bool `json:"shortLink,omitempty"`
+	Interstitial bool `json:"interstitial,omitempty"`
+
+	// Name of the API client that created the item, if any
+	Owner string `json:"owner,omitempty"`
+}
+
+// ExpiresAt returns when the item expires, and false if it never does.
+func (i Item) ExpiresAt() (time.Time, bool) {
+	if i.NeverExpires {
+		return time.Time{}, false
+	}
+	if !i.Expires.IsZero() {
+		return i.Expires, true
+	}
+	return i.Created.Add(time.Duration(i.LifetimeHours) * time.Hour), true
+}
+
+// ItemRevision is an earlier version of the item content.
+type ItemRevision struct {
+	Created  time.Time   `json:"created"`
+	Language string      `json:"language,omitempty"`
+	Content  ItemContent `json:"content"`
+}
+
+// ItemFile is one named text file in an item. Content is encoded the same
+// way as the item content.
+type ItemFile struct {
+	Name     string      `json:"name"`
+	Language string      `json:"language,omitempty"`
+	Content  ItemContent `json:"content"`
+}
+
+// Attachment is a binary file stored with an item. Data is base64 encoded
+// like the item content.
+type Attachment struct {
+	Name     string      `json:"name"`
+	Size     int64       `json:"size"`
+	MimeType string      `json:"mimeType"`
+	Data     ItemContent `json:"data"`
+	// Set for images
+	Width     int         `json:"width,omitempty"`
+	Height    int         `json:"height,omitempty"`
+	Thumbnail ItemContent `json:"thumbnail,omitempty"`
+}
+
+func NewAttachment(name string, mimeType string, data []byte) Attachment {
+	return Attachment{
+		Name:     name,
+		Size:     int64(len(data)),
+		MimeType: mimeType,
+		Data:     ItemContent(base64.StdEncoding.EncodeToString(data)),
+	}
+}
+
+func DecodeAttachment(a Attachment) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(string(a.Data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode attachment: %v", err)
+	}
+	return data, nil
 }
 
 func (h *CosmosHandler) NewItem(content string, lifetimeHours int, password string, deleteOnRead bool) *Item {
@@ -201,7 +315,8 @@ func (h *CosmosHandler) CreateItem(itemID ItemID, item *Item) error {
 	// Specifies the value of the partiton key
 	pk := azcosmos.NewPartitionKeyString(h.Partition)
 
-	b, err := json.Marshal(item)
+	ctx := context.Background()
+	b, chunks, err := h.prepareItem(ctx, containerClient, item, nil)
 	if err != nil {
 		return err
 	}
@@ -210,10 +325,10 @@ func (h *CosmosHandler) CreateItem(itemID ItemID, item *Item) error {
 	itemOptions := azcosmos.ItemOptions{
 		ConsistencyLevel: azcosmos.ConsistencyLevelSession.ToPtr(),
 	}
-	ctx := context.Background()
 	itemResponse, err := containerClient.CreateItem(ctx, pk, b, &itemOptions)
 
 	if err != nil {
+		h.discardChunks(ctx, containerClient, item.Id, chunks)
 		return err
 	}
 	slog.Info("item created", "id", item.Id, "activityId", itemResponse.ActivityID, "requestCharge", itemResponse.RequestCharge)
@@ -242,10 +357,146 @@ func (h *CosmosHandler) ReadItem(itemID ItemID) (*Item, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal item: %v", err)
 	}
+	if item.Chunks != nil {
+		assembled, err := h.assembleItem(ctx, containerClient, &item)
+		if err != nil {
+			return nil, fmt.Errorf("failed to assemble item: %v", err)
+		}
+		// the view count is kept up to date on the manifest only
+		assembled.Views = item.Views
+		item = *assembled
+	}
+	err = decompressItem(&item)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress item: %v", err)
+	}
 
 	return &item, nil
 }
 
+// ItemExists checks for an item without reading its content or chunks.
+func (h *CosmosHandler) ItemExists(itemID ItemID) (bool, error) {
+	pk := azcosmos.NewPartitionKeyString(h.Partition)
+	opts := &azcosmos.QueryOptions{
+		QueryParameters: []azcosmos.QueryParameter{
+			{Name: "@id", Value: string(itemID)},
+		},
+	}
+	queryPager := h.ContainerClient.NewQueryItemsPager("SELECT VALUE COUNT(1) FROM c WHERE c.id = @id", pk, opts)
+	for queryPager.More() {
+		queryResponse, err := queryPager.NextPage(context.TODO())
+		if err != nil {
+			return false, fmt.Errorf("failed to query item: %v", err)
+		}
+		for _, respItem := range queryResponse.Items {
+			var count int
+			err := json.Unmarshal(respItem, &count)
+			if err != nil {
+				return false, fmt.Errorf("failed to unmarshal count: %v", err)
+			}
+			if count > 0 {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// ReadItemMetadata reads when an item expires and which networks may read
+// it, without its content or chunks.
+func (h *CosmosHandler) ReadItemMetadata(itemID ItemID) (*Item, error) {
+	pk := azcosmos.NewPartitionKeyString(h.Partition)
+	query := "SELECT c.id, c.partition, c.lifetimeHours, c.expires, c.neverExpires, c.created, c.allowedNetworks " +
+		"FROM docs c WHERE c.id = @id AND NOT IS_DEFINED(c.chunkOf)"
+	opts := &azcosmos.QueryOptions{
+		QueryParameters: []azcosmos.QueryParameter{
+			{Name: "@id", Value: string(itemID)},
+		},
+	}
+	queryPager := h.ContainerClient.NewQueryItemsPager(query, pk, opts)
+	for queryPager.More() {
+		queryResponse, err := queryPager.NextPage(context.TODO())
+		if err != nil {
+			return nil, fmt.Errorf("failed to query item: %v", err)
+		}
+		for _, respItem := range queryResponse.Items {
+			var item Item
+			err := json.Unmarshal(respItem, &item)
+			if err != nil {
+				return nil, fmt.Errorf("failed to unmarshal item: %v", err)
+			}
+			return &item, nil
+		}
+	}
+	return nil, fmt.Errorf("item not found")
+}
+
+func (h *CosmosHandler) ReplaceItem(itemID ItemID, item *Item) error {
+	slog.Debug("replacing item")
+	containerClient, err := h.Client.NewContainer(h.DatabaseName, h.ContainerName)
+	if err != nil {
+		return fmt.Errorf("failed to create a container client: %s", err)
+	}
+
+	// Specifies the value of the partiton key
+	pk := azcosmos.NewPartitionKeyString(h.Partition)
+
+	ctx := context.TODO()
+	old := h.storedManifest(ctx, containerClient, itemID)
+	b, chunks, err := h.prepareItem(ctx, containerClient, item, old)
+	if err != nil {
+		return err
+	}
+
+	itemResponse, err := containerClient.ReplaceItem(ctx, pk, string(itemID), b, nil)
+	if err != nil {
+		if !sameChunks(old, chunks) {
+			h.discardChunks(ctx, containerClient, itemID, chunks)
+		}
+		return fmt.Errorf("failed to replace item: %v", err)
+	}
+	slog.Debug("item replaced", "id", itemID, "activityId", itemResponse.ActivityID, "requestCharge", itemResponse.RequestCharge)
+
+	// the old chunks are unreachable now, unless the content didn't change
+	if !sameChunks(old, chunks) {
+		h.discardChunks(ctx, containerClient, itemID, old)
+	}
+	return nil
+}
+
+// ErrViewLimit is returned by CountView when the item has no views left.
+var ErrViewLimit = errors.New("item has no views left")
+
+// CountView adds a view to the item and returns the new count. The increment
+// happens in Cosmos, so concurrent readers can't lose counts, and with a
+// maxViews above 0 it only applies while the count is below it, so no more
+// than maxViews reads ever succeed. It only touches the item document, so
+// counting a view of a chunked item doesn't rewrite its chunks.
+func (h *CosmosHandler) CountView(itemID ItemID, maxViews int) (int, error) {
+	pk := azcosmos.NewPartitionKeyString(h.Partition)
+	ops := azcosmos.PatchOperations{}
+	if maxViews > 0 {
+		ops.SetCondition(fmt.Sprintf("FROM c WHERE NOT IS_DEFINED(c.views) OR c.views < %d", maxViews))
+	}
+	ops.AppendIncrement("/views", 1)
+	opts := &azcosmos.ItemOptions{EnableContentResponseOnWrite: true}
+	itemResponse, err := h.ContainerClient.PatchItem(context.TODO(), pk, string(itemID), ops, opts)
+	if azRespErr, ok := err.(*azcore.ResponseError); ok && azRespErr.StatusCode == http.StatusPreconditionFailed {
+		return 0, ErrViewLimit
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to count view: %v", err)
+	}
+	var counted struct {
+		Views int `json:"views"`
+	}
+	err = json.Unmarshal(itemResponse.Value, &counted)
+	if err != nil {
+		return 0, fmt.Errorf("failed to unmarshal views: %v", err)
+	}
+	return counted.Views, nil
+}
+
 func (h *CosmosHandler) DeleteItem(itemID ItemID) error {
 	slog.Debug("deleting item")
 	containerClient, err := h.Client.NewContainer(h.DatabaseName, h.ContainerName)
@@ -257,6 +508,10 @@ func (h *CosmosHandler) DeleteItem(itemID ItemID) error {
 	pk := azcosmos.NewPartitionKeyString(h.Partition)
 
 	ctx := context.TODO()
+	err = h.deleteChunks(ctx, containerClient, itemID)
+	if err != nil {
+		return fmt.Errorf("failed to delete item chunks: %v", err)
+	}
 	itemResponse, err := containerClient.DeleteItem(ctx, pk, string(itemID), nil)
 	if err != nil {
 		return fmt.Errorf("failed to delete item: %v", err)
@@ -269,7 +524,8 @@ func (h *CosmosHandler) DeleteItem(itemID ItemID) error {
 func (h *CosmosHandler) GetAllItems() ([]Item, error) {
 	slog.Debug("getting all items")
 	pk := azcosmos.NewPartitionKeyString(h.Partition)
-	queryPager := h.ContainerClient.NewQueryItemsPager("SELECT * FROM docs c", pk, nil)
+	// chunks are removed along with their item, skip them
+	queryPager := h.ContainerClient.NewQueryItemsPager("SELECT * FROM docs c WHERE NOT IS_DEFINED(c.chunkOf)", pk, nil)
 	allItems := []Item{}
 	for queryPager.More() {
 		queryResponse, err := queryPager.NextPage(context.Background())
@@ -287,16 +543,64 @@ func (h *CosmosHandler) GetAllItems() ([]Item, error) {
 				}
 				err = json.Unmarshal(respItem, &idStruct)
 				if err != nil {
-					slog.Error("failed to unmarshal corrupt item: %v", err)
+					slog.Error("failed to unmarshal corrupt item: "+err.Error(), "source", "GetAllItems")
 				} else {
 					// delete the corrupt item
 					slog.Debug("deleting corrupt item", "id", idStruct.Id)
 					h.DeleteItem(ItemID(idStruct.Id))
 				}
-
+				continue
+			}
+			err = decompressItem(&item)
+			if err != nil {
+				slog.Error("failed to decompress item: "+err.Error(), "id", string(item.Id), "source", "GetAllItems")
 			}
 			allItems = append(allItems, item)
 		}
 	}
 	return allItems, nil
 }
+
+// ListItems returns the items created by owner and tagged with tag, newest
+// first. Empty arguments match every item, expired ones never do. Only the
+// metadata of the items is read, content, files and attachments are left
+// empty.
+func (h *CosmosHandler) ListItems(owner string, tag string) ([]Item, error) {
+	slog.Debug("listing items", "owner", owner, "tag", tag)
+	pk := azcosmos.NewPartitionKeyString(h.Partition)
+	query := "SELECT c.id, c.partition, c.lifetimeHours, c.expires, c.neverExpires, c.deleteOnRead, c.created, c.language, c.contentType, c.format, " +
+		"c.maxViews, c.views, c.updated, c.forkOf, c.title, c.description, c.tags, c.owner " +
+		"FROM docs c WHERE NOT IS_DEFINED(c.chunkOf) " +
+		"AND (@owner = '' OR c.owner = @owner) " +
+		"AND (@tag = '' OR ARRAY_CONTAINS(c.tags, @tag)) " +
+		"ORDER BY c.created DESC"
+	opts := &azcosmos.QueryOptions{
+		QueryParameters: []azcosmos.QueryParameter{
+			{Name: "@owner", Value: owner},
+			{Name: "@tag", Value: tag},
+		},
+	}
+	queryPager := h.ContainerClient.NewQueryItemsPager(query, pk, opts)
+	items := []Item{}
+	for queryPager.More() {
+		queryResponse, err := queryPager.NextPage(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get next page: %v", err)
+		}
+		for _, respItem := range queryResponse.Items {
+			var item Item
+			err := json.Unmarshal(respItem, &item)
+			if err != nil {
+				slog.Error("failed to unmarshal item: "+err.Error(), "source", "ListItems")
+				continue
+			}
+			// the cleaner may not have gotten to it yet. Older items only
+			// have lifetimeHours, so this is checked here and not in the query.
+			if expires, ok := item.ExpiresAt(); ok && time.Now().After(expires) {
+				continue
+			}
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
@@ -11,6 +11,7 @@ type CosmosConfig struct {
 	DatabaseName  string
 	ContainerName string
 	Partition     string
+	Compression   string
 }
 
 func GetDBConfig() (*CosmosConfig, error) {
@@ -36,11 +37,23 @@ func GetDBConfig() (*CosmosConfig, error) {
 		return nil, fmt.Errorf("COSMOS_PARTITION environment variable not set")
 	}
 
+	compression, found := os.LookupEnv("COSMOS_COMPRESSION")
+	if !found {
+		compression = EncodingGzip
+	}
+	if compression == "none" {
+		compression = EncodingNone
+	}
+	if compression != EncodingNone && compression != EncodingGzip {
+		return nil, fmt.Errorf("COSMOS_COMPRESSION must be %q or %q", EncodingGzip, "none")
+	}
+
 	return &CosmosConfig{
 		Endpoint:      endpoint,
 		Key:           key,
 		DatabaseName:  databaseName,
 		ContainerName: containerName,
 		Partition:     partition,
+		Compression:   compression,
 	}, nil
 }
@@ -0,0 +1,143 @@
+package web
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lcrownover/duckpaste/internal/audit"
+	"github.com/lcrownover/duckpaste/internal/db"
+)
+
+// Reads within this long of creation don't burn the paste or count as a
+// view, so the creator can be redirected to it and copy the link.
+const burnGracePeriod = 10 * time.Second
+
+// readError is returned by openPaste with the status the request should
+// be answered with.
+type readError struct {
+	status  int
+	message string
+}
+
+func (e *readError) Error() string {
+	return e.message
+}
+
+// readStatus returns the HTTP status for an error from openPaste.
+func readStatus(err error) int {
+	if re, ok := err.(*readError); ok {
+		return re.status
+	}
+	return http.StatusInternalServerError
+}
+
+// requestPassword finds the paste password in the request. It can come from
+// the X-Paste-Password header, basic auth (curl -u :password) or the
+// password form on the paste page.
+func requestPassword(c *gin.Context) string {
+	if password := c.GetHeader("X-Paste-Password"); password != "" {
+		return password
+	}
+	if _, password, ok := c.Request.BasicAuth(); ok {
+		return password
+	}
+	return c.PostForm("pastePassword")
+}
+
+func passwordMatches(paste PasteEntry, password string) bool {
+	if paste.Password == "" {
+		return true
+	}
+	supplied := string(db.EncodeContent(password))
+	return subtle.ConstantTimeCompare([]byte(supplied), []byte(paste.Password)) == 1
+}
+
+// openPaste is how every handler reads a paste. It applies the network and
+// password restrictions, counts the view and burns the paste if this was
+// its last allowed read. Any route that hands out content counts as a view
+// this way, whether it's the page, the API, a fork, a diff, an old revision,
+// a short link or a hastebin read.
+func openPaste(c *gin.Context, pasteID string) (PasteEntry, error) {
+	paste, err := checkPaste(c, pasteID, requestPassword(c))
+	if err != nil {
+		return paste, err
+	}
+	return consumePaste(c, paste)
+}
+
+// checkPaste fetches the paste and checks the client may read it, without
+// counting it as a read. Handlers that read several pastes check them all
+// before consuming any, so a failure on one doesn't burn the others.
+func checkPaste(c *gin.Context, pasteID string, password string) (PasteEntry, error) {
+	paste, err := getPasteEntry(pasteID)
+	if err != nil {
+		recordEvent(c, audit.ActionRead, pasteID, audit.OutcomeFailure, err.Error())
+		return paste, &readError{http.StatusNotFound, fmt.Sprintf("no paste found with id: %s", pasteID)}
+	}
+
+	if !pasteAllowsClient(c, paste) {
+		return paste, &readError{http.StatusForbidden, "this paste is not viewable from your network"}
+	}
+
+	if !passwordMatches(paste, password) {
+		recordEvent(c, audit.ActionRead, paste.Id, audit.OutcomeDenied, "wrong or missing password")
+		return paste, &readError{http.StatusUnauthorized, "this paste is password protected"}
+	}
+
+	return paste, nil
+}
+
+// consumePaste counts a read of a checked paste, burning it if needed. Reads
+// of short links are always counted, as clicks.
+func consumePaste(c *gin.Context, paste PasteEntry) (PasteEntry, error) {
+	var err error
+	inGracePeriod := paste.Created.Add(burnGracePeriod).After(time.Now())
+	burn := paste.DeleteOnRead && !inGracePeriod
+
+	if (paste.MaxViews > 0 || paste.ShortLink || burn) && !inGracePeriod {
+		// the count is checked and raised in one step in the database, so
+		// concurrent reads can't both take the last view. A burn after read
+		// paste has exactly one view left.
+		limit := paste.MaxViews
+		if burn {
+			limit = paste.Views + 1
+		}
+		views, err := countPasteView(paste, limit)
+		switch {
+		case errors.Is(err, db.ErrViewLimit):
+			recordEvent(c, audit.ActionRead, paste.Id, audit.OutcomeFailure, "no views left")
+			return paste, &readError{http.StatusNotFound, fmt.Sprintf("no paste found with id: %s", paste.Id)}
+		case err != nil && limit > 0:
+			// without the count the limit can't be enforced
+			recordEvent(c, audit.ActionRead, paste.Id, audit.OutcomeFailure, err.Error())
+			return paste, &readError{http.StatusInternalServerError, "failed to count view"}
+		case err != nil:
+			slog.Error("failed to count view: "+err.Error(), "id", paste.Id, "source", "consumePaste")
+			paste.Views++
+		default:
+			paste.Views = views
+		}
+		if paste.MaxViews > 0 && paste.Views >= paste.MaxViews {
+			burn = true
+		}
+	}
+
+	recordEvent(c, audit.ActionRead, paste.Id, audit.OutcomeSuccess, "")
+
+	if burn {
+		err = deletePasteEntry(paste)
+		if err != nil {
+			recordEvent(c, audit.ActionBurn, paste.Id, audit.OutcomeFailure, err.Error())
+			return paste, &readError{http.StatusInternalServerError, fmt.Sprintf("failed to delete paste: %s", err)}
+		}
+		recordEvent(c, audit.ActionBurn, paste.Id, audit.OutcomeSuccess, "")
+		paste.Burned = true
+	}
+
+	return paste, nil
+}
@@ -0,0 +1,160 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lcrownover/duckpaste/internal/audit"
+	"github.com/lcrownover/duckpaste/internal/db"
+)
+
+// Version 1 of the JSON API lives under /api/v1. Responses carry decoded
+// content and never include passwords or token hashes. The older /api/paste
+// routes answer with the same shapes.
+
+// createResponse answers a JSON request creating a paste. The delete token
+// is the paste's edit token, so it also allows editing.
+type createResponse struct {
+	Id          string     `json:"id"`
+	URL         string     `json:"url"`
+	RawURL      string     `json:"rawUrl"`
+	ContentType string     `json:"contentType"`
+	Language    string     `json:"language"`
+	ExpiresAt   *time.Time `json:"expiresAt"`
+	DeleteToken string     `json:"deleteToken"`
+}
+
+// pasteResponse is a paste as the API returns it.
+type pasteResponse struct {
+	Id                string            `json:"id"`
+	URL               string            `json:"url"`
+	RawURL            string            `json:"rawUrl"`
+	Title             string            `json:"title"`
+	Description       string            `json:"description"`
+	Tags              []string          `json:"tags"`
+	Content           string            `json:"content"`
+	Language          string            `json:"language"`
+	ContentType       string            `json:"contentType"`
+	Format            string            `json:"format"`
+	Files             []FileEntry       `json:"files"`
+	Attachments       []AttachmentEntry `json:"attachments"`
+	Revisions         []RevisionEntry   `json:"revisions"`
+	Created           time.Time         `json:"created"`
+	Updated           *time.Time        `json:"updated,omitempty"`
+	ExpiresAt         *time.Time        `json:"expiresAt"`
+	PasswordProtected bool              `json:"passwordProtected"`
+	DeleteOnRead      bool              `json:"deleteOnRead"`
+	MaxViews          int               `json:"maxViews"`
+	Views             int               `json:"views"`
+	Burned            bool              `json:"burned"`
+	ForkOf            string            `json:"forkOf,omitempty"`
+	ShortLink         bool              `json:"shortLink"`
+	Interstitial      bool              `json:"interstitial"`
+}
+
+func (h *WebHandler) newPasteResponse(paste PasteEntry) (pasteResponse, error) {
+	content, err := db.DecodeContent(db.ItemContent(paste.Content))
+	if err != nil {
+		return pasteResponse{}, err
+	}
+	files := []FileEntry{}
+	for _, f := range paste.Files {
+		f.Content, err = db.DecodeContent(db.ItemContent(f.Content))
+		if err != nil {
+			return pasteResponse{}, err
+		}
+		files = append(files, f)
+	}
+	var updated *time.Time
+	if !paste.Updated.IsZero() {
+		updated = &paste.Updated
+	}
+	return pasteResponse{
+		Id:                paste.Id,
+		URL:               h.pasteURL(paste.Id),
+		RawURL:            h.rawURL(paste.Id),
+		Title:             paste.Title,
+		Description:       paste.Description,
+		Tags:              paste.Tags,
+		Content:           content,
+		Language:          paste.Language,
+		ContentType:       paste.ContentType,
+		Format:            paste.Format,
+		Files:             files,
+		Attachments:       paste.Attachments,
+		Revisions:         paste.Revisions,
+		Created:           paste.Created,
+		Updated:           updated,
+		ExpiresAt:         paste.Expires,
+		PasswordProtected: paste.Password != "",
+		DeleteOnRead:      paste.DeleteOnRead,
+		MaxViews:          paste.MaxViews,
+		Views:             paste.Views,
+		Burned:            paste.Burned,
+		ForkOf:            paste.ForkOf,
+		ShortLink:         paste.ShortLink,
+		Interstitial:      paste.Interstitial,
+	}, nil
+}
+
+// respondPaste opens the paste and answers with it.
+func (h *WebHandler) respondPaste(c *gin.Context, pasteID string) {
+	paste, err := openPaste(c, pasteID)
+	if err != nil {
+		if readStatus(err) == http.StatusUnauthorized {
+			c.Header("WWW-Authenticate", `Basic realm="duckpaste"`)
+		}
+		c.JSON(readStatus(err), errorResponse{
+			err.Error(),
+		})
+		return
+	}
+	h.respondPasteEntry(c, http.StatusOK, paste)
+}
+
+func (h *WebHandler) respondPasteEntry(c *gin.Context, status int, paste PasteEntry) {
+	resp, err := h.newPasteResponse(paste)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse{
+			"failed to decode content of paste",
+		})
+		return
+	}
+	c.JSON(status, resp)
+}
+
+// getPasteV1 handles GET /api/v1/pastes/:pasteId
+func (h *WebHandler) getPasteV1(c *gin.Context) {
+	h.respondPaste(c, c.Param("pasteId"))
+}
+
+// deletePasteApi handles DELETE /api/v1/pastes/:pasteId. The delete token
+// goes in X-Delete-Token, or anywhere the edit token is accepted.
+func (h *WebHandler) deletePasteApi(c *gin.Context) {
+	pasteID := c.Param("pasteId")
+	token := c.GetHeader("X-Delete-Token")
+	if token == "" {
+		token = requestEditToken(c, pasteID)
+	}
+	err := deletePasteWithToken(pasteID, token)
+	if err != nil {
+		outcome := audit.OutcomeFailure
+		if readStatus(err) == http.StatusForbidden {
+			outcome = audit.OutcomeDenied
+		}
+		recordEvent(c, audit.ActionDelete, pasteID, outcome, err.Error())
+		c.JSON(readStatus(err), errorResponse{
+			err.Error(),
+		})
+		return
+	}
+	recordEvent(c, audit.ActionDelete, pasteID, audit.OutcomeSuccess, "")
+	c.Status(http.StatusNoContent)
+}
+
+// rawURL is the address of the paste's raw content.
+func (h *WebHandler) rawURL(pasteID string) string {
+	return fmt.Sprintf("http://%s/raw/%s", h.config.Address(), pasteID)
+}
@@ -0,0 +1,247 @@
+package web
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/gin-gonic/gin"
+	"github.com/lcrownover/duckpaste/internal/db"
+)
+
+const (
+	// Attachments live in the paste document, so together they have to fit
+	// comfortably under the 2MB Cosmos document limit once base64 encoded.
+	maxAttachmentBytes int64 = 1 << 20
+	maxAttachments     int   = 10
+)
+
+// A visit to the paste page counts as one view, attachments included. The
+// page hands the browser a visit cookie scoped to the paste's attachments,
+// and downloads made with it don't count again or ask for the password
+// again. Downloads without one, like a link passed on to someone else,
+// count as a view of their own. Expiry and network limits always apply.
+const (
+	visitCookie   string        = "visit"
+	visitLifetime time.Duration = time.Hour
+)
+
+// visitKey signs visit cookies. It's made at startup, so after a restart a
+// download counts as a view again.
+var visitKey = func() []byte {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	if err != nil {
+		panic(fmt.Sprintf("failed to make visit key: %v", err))
+	}
+	return key
+}()
+
+// visitToken is the expiry of the visit and a signature over it and the
+// paste id.
+func visitToken(pasteID string, expires int64) string {
+	mac := hmac.New(sha256.New, visitKey)
+	fmt.Fprintf(mac, "%s|%d", pasteID, expires)
+	return fmt.Sprintf("%d.%s", expires, hex.EncodeToString(mac.Sum(nil)))
+}
+
+// giveVisitToken lets the browser download the attachments of a paste it
+// was just shown. Burned pastes have theirs embedded in the page instead.
+func giveVisitToken(c *gin.Context, paste PasteEntry) {
+	if len(paste.Attachments) == 0 || paste.Burned {
+		return
+	}
+	expires := time.Now().Add(visitLifetime).Unix()
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(visitCookie, visitToken(paste.Id, expires), int(visitLifetime.Seconds()), "/attachment/"+paste.Id, "", c.Request.TLS != nil, true)
+}
+
+// visitedPaste reports whether the request carries a current visit cookie
+// for the paste.
+func visitedPaste(c *gin.Context, pasteID string) bool {
+	token, err := c.Cookie(visitCookie)
+	if err != nil {
+		return false
+	}
+	s, _, _ := strings.Cut(token, ".")
+	expires, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return false
+	}
+	return hmac.Equal([]byte(token), []byte(visitToken(pasteID, expires)))
+}
+
+// AttachmentEntry describes an attachment without its data.
+type AttachmentEntry struct {
+	Name     string `json:"name"`
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Width    int    `json:"width,omitempty"`
+	Height   int    `json:"height,omitempty"`
+	URL      string `json:"url"`
+}
+
+func NewAttachmentEntries(pasteID string, attachments []db.Attachment) []AttachmentEntry {
+	entries := []AttachmentEntry{}
+	for i, a := range attachments {
+		entries = append(entries, AttachmentEntry{
+			Name:     a.Name,
+			Size:     a.Size,
+			MimeType: a.MimeType,
+			Width:    a.Width,
+			Height:   a.Height,
+			URL:      fmt.Sprintf("/attachment/%s/%d", pasteID, i),
+		})
+	}
+	return entries
+}
+
+// readAttachments pulls the uploaded pasteFile parts out of a multipart
+// request. Other request types have no attachments.
+func readAttachments(c *gin.Context) ([]db.Attachment, error) {
+	if c.ContentType() != gin.MIMEMultipartPOSTForm {
+		return nil, nil
+	}
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read multipart form: %v", err)
+	}
+	files := form.File["pasteFile"]
+	if len(files) > maxAttachments {
+		return nil, fmt.Errorf("too many files, the limit is %d", maxAttachments)
+	}
+
+	var total int64
+	attachments := []db.Attachment{}
+	for _, fh := range files {
+		// browsers send an empty part when no file was picked
+		if fh.Filename == "" && fh.Size == 0 {
+			continue
+		}
+		total += fh.Size
+		if total > maxAttachmentBytes {
+			return nil, fmt.Errorf("attachments are too large, the limit is %d bytes", maxAttachmentBytes)
+		}
+		f, err := fh.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %v", fh.Filename, err)
+		}
+		data, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", fh.Filename, err)
+		}
+		// don't trust the client's content type, look at the bytes
+		name := filepath.Base(fh.Filename)
+		mimeType := mimetype.Detect(data).String()
+		if !isImageMimeType(mimeType) {
+			attachments = append(attachments, db.NewAttachment(name, mimeType, data))
+			continue
+		}
+		img, err := processImage(name, mimeType, data)
+		if err != nil {
+			return nil, err
+		}
+		attachment := db.NewAttachment(img.Name, img.MimeType, img.Data)
+		attachment.Width = img.Width
+		attachment.Height = img.Height
+		attachment.Thumbnail = db.ItemContent(base64.StdEncoding.EncodeToString(img.Thumbnail))
+		attachments = append(attachments, attachment)
+	}
+	return attachments, nil
+}
+
+// attachmentLink is an attachment as listed on the paste page.
+type attachmentLink struct {
+	AttachmentEntry
+	Href template.URL
+	// Set for images, which are shown inline
+	Preview   template.URL
+	Thumbnail template.URL
+}
+
+// attachmentLinks lists the paste's attachments for the paste page. If the
+// view burned the paste the data is embedded, since the links would be dead.
+func attachmentLinks(paste PasteEntry) []attachmentLink {
+	links := []attachmentLink{}
+	for i, entry := range paste.Attachments {
+		href := template.URL(entry.URL)
+		if paste.Burned {
+			href = attachmentDataURL(paste.attachments[i])
+		}
+		link := attachmentLink{AttachmentEntry: entry, Href: href}
+		if a := paste.attachments[i]; isImageMimeType(a.MimeType) {
+			link.Preview = imageDataURL(a.MimeType, string(a.Data))
+			if a.Thumbnail != "" {
+				link.Thumbnail = imageDataURL("image/png", string(a.Thumbnail))
+			}
+		}
+		links = append(links, link)
+	}
+	return links
+}
+
+// attachmentDataURL embeds an attachment into the paste page, for pastes
+// that were burned by the view and can't be fetched again.
+func attachmentDataURL(a db.Attachment) template.URL {
+	return template.URL("data:application/octet-stream;base64," + string(a.Data))
+}
+
+// getAttachment serves an attachment as a download. It's always sent as
+// application/octet-stream so the browser never renders it in our origin.
+func (h *WebHandler) getAttachment(c *gin.Context) {
+	pasteID := c.Param("pasteId")
+	var paste PasteEntry
+	var err error
+	visited := visitedPaste(c, pasteID)
+	if visited {
+		paste, err = getPasteEntry(pasteID)
+		if err != nil {
+			err = &readError{http.StatusNotFound, fmt.Sprintf("no paste found with id: %s", pasteID)}
+		} else if !pasteAllowsClient(c, paste) {
+			err = &readError{http.StatusForbidden, "this paste is not viewable from your network"}
+		}
+	} else {
+		paste, err = checkPaste(c, pasteID, requestPassword(c))
+	}
+	if err != nil {
+		c.String(readStatus(err), err.Error()+"\n")
+		return
+	}
+	// the index is checked before the read is counted, so asking for an
+	// attachment that doesn't exist doesn't burn the paste
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil || index < 0 || index >= len(paste.attachments) {
+		c.String(http.StatusNotFound, "no attachment found\n")
+		return
+	}
+	if !visited {
+		paste, err = consumePaste(c, paste)
+		if err != nil {
+			c.String(readStatus(err), err.Error()+"\n")
+			return
+		}
+	}
+	attachment := paste.attachments[index]
+	data, err := db.DecodeAttachment(attachment)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "failed to decode attachment\n")
+		return
+	}
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Content-Security-Policy", "sandbox")
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Name}))
+	c.Data(http.StatusOK, "application/octet-stream", data)
+}
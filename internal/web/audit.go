@@ -0,0 +1,20 @@
+package web
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/lcrownover/duckpaste/internal/audit"
+)
+
+// recordEvent writes an audit event for the paste, filling in the client
+// details from the request.
+func recordEvent(c *gin.Context, action audit.Action, pasteID string, outcome audit.Outcome, detail string) {
+	audit.Record(audit.Event{
+		Action:    action,
+		PasteID:   pasteID,
+		ClientIP:  c.ClientIP(),
+		User:      c.GetString(apiUserKey),
+		UserAgent: c.Request.UserAgent(),
+		Outcome:   outcome,
+		Detail:    detail,
+	})
+}
@@ -3,6 +3,8 @@ package web
 import (
 	"fmt"
 	"log/slog"
+	"net/http"
+	"time"
 
 	"github.com/lcrownover/duckpaste/internal/db"
 )
@@ -13,13 +15,46 @@ func NewPasteEntryFromDbItem(item db.Item) PasteEntry {
 	return PasteEntry{
 		Id:              string(item.Id),
 		ExpirationHours: item.LifetimeHours,
+		Expires:         itemExpiry(item),
 		Content:         string(item.Content),
 		Password:        string(item.Password),
 		DeleteOnRead:    item.DeleteOnRead,
+		AllowedNetworks: item.AllowedNetworks,
+		Language:        item.Language,
+		ContentType:     item.ContentType,
+		Format:          item.Format,
+		MaxViews:        item.MaxViews,
+		Views:           item.Views,
+		Attachments:     NewAttachmentEntries(string(item.Id), item.Attachments),
+		attachments:     item.Attachments,
+		Files:           NewFileEntriesFromDbItem(item),
+		Revisions:       NewRevisionEntries(item),
+		revisions:       item.Revisions,
+		Updated:         item.Updated,
+		ForkOf:          string(item.ForkOf),
+		Title:           item.Title,
+		Description:     item.Description,
+		Tags:            item.Tags,
+		ShortLink:       item.ShortLink,
+		Interstitial:    item.Interstitial,
 		Created:         item.Created,
 	}
 }
 
+// itemExpiry returns when the item expires, nil if it never does.
+func itemExpiry(item db.Item) *time.Time {
+	expires, ok := item.ExpiresAt()
+	if !ok {
+		return nil
+	}
+	return &expires
+}
+
+func isExpired(item db.Item) bool {
+	expires, ok := item.ExpiresAt()
+	return ok && time.Now().After(expires)
+}
+
 func getPasteEntry(id string) (PasteEntry, error) {
 	// get it
 	slog.Info("getting paste", "id", id, "source", "getPasteEntry")
@@ -27,22 +62,60 @@ func getPasteEntry(id string) (PasteEntry, error) {
 	if err != nil {
 		return PasteEntry{}, fmt.Errorf("paste not found")
 	}
+	// the cleaner may not have gotten to it yet
+	if isExpired(*pasteEntry) {
+		return PasteEntry{}, fmt.Errorf("paste not found")
+	}
 
 	return NewPasteEntryFromDbItem(*pasteEntry), nil
 }
 
-func createPasteEntry(p PasteEntry) (PasteEntry, error) {
-	if p.ExpirationHours == 0 {
-		p.ExpirationHours = defaultLifetime
+func createPasteEntry(p PasteEntry, attachments []db.Attachment) (PasteEntry, error) {
+	p.ContentType = ""
+	if p.Content != "" {
+		p.ContentType = detectContentType(p.Content)
+	}
+	if p.Language == "" && p.Content != "" {
+		p.Language = defaultLanguageFor(p.ContentType, p.Content)
+	}
+
+	editToken, err := newEditToken()
+	if err != nil {
+		return p, err
 	}
 
 	//convert
-	newDbItem := dbClient.NewItem(p.Content, p.ExpirationHours, p.Password, p.DeleteOnRead)
+	newDbItem := dbClient.NewItem(p.Content, 0, p.Password, p.DeleteOnRead)
+	if p.Expires != nil {
+		newDbItem.Expires = *p.Expires
+		newDbItem.LifetimeHours = lifetimeHours(newDbItem.Created, p.Expires)
+	} else {
+		newDbItem.NeverExpires = true
+	}
+	newDbItem.AllowedNetworks = p.AllowedNetworks
+	newDbItem.Language = p.Language
+	newDbItem.ContentType = p.ContentType
+	newDbItem.Format = p.Format
+	newDbItem.MaxViews = p.MaxViews
+	newDbItem.Attachments = attachments
+	newDbItem.Files = NewDbFiles(p.Files)
+	newDbItem.EditToken = hashEditToken(editToken)
+	newDbItem.ForkOf = db.ItemID(p.ForkOf)
+	newDbItem.Title = p.Title
+	newDbItem.Description = p.Description
+	newDbItem.Tags = p.Tags
+	newDbItem.ShortLink = p.ShortLink
+	newDbItem.Interstitial = p.Interstitial
+	newDbItem.Owner = p.owner
 	p.Id = string(newDbItem.Id)
+	p.Attachments = NewAttachmentEntries(p.Id, attachments)
+	p.EditToken = editToken
+	p.Created = newDbItem.Created
+	p.ExpirationHours = newDbItem.LifetimeHours
 
 	// put it in the database
 	slog.Info("creating paste", "id", p.Id, "source", "createPasteEntry")
-	err := dbClient.CreateItem(newDbItem.Id, newDbItem)
+	err = dbClient.CreateItem(newDbItem.Id, newDbItem)
 	if err != nil {
 		return p, err
 	}
@@ -50,6 +123,46 @@ func createPasteEntry(p PasteEntry) (PasteEntry, error) {
 	return p, nil
 }
 
+// editPasteEntry replaces the paste content, keeping the current content
+// as a revision.
+func editPasteEntry(id string, editToken string, update pasteUpdate) (PasteEntry, error) {
+	slog.Info("editing paste", "id", id, "source", "editPasteEntry")
+	item, err := dbClient.ReadItem(db.ItemID(id))
+	if err != nil || isExpired(*item) {
+		return PasteEntry{}, &readError{http.StatusNotFound, fmt.Sprintf("no paste found with id: %s", id)}
+	}
+	if !editTokenMatches(*item, editToken) {
+		return PasteEntry{}, &readError{http.StatusForbidden, "wrong or missing edit token"}
+	}
+	// changing where a shared link goes would be a phishing tool
+	if item.ShortLink {
+		return PasteEntry{}, &readError{http.StatusBadRequest, "short links can't be edited"}
+	}
+
+	versionCreated := item.Updated
+	if versionCreated.IsZero() {
+		versionCreated = item.Created
+	}
+	item.Revisions = append(item.Revisions, db.ItemRevision{
+		Created:  versionCreated,
+		Language: item.Language,
+		Content:  item.Content,
+	})
+	item.Content = db.EncodeContent(update.Content)
+	item.ContentType = detectContentType(update.Content)
+	item.Language = update.Language
+	if item.Language == "" {
+		item.Language = defaultLanguageFor(item.ContentType, update.Content)
+	}
+	item.Updated = db.GetCurrentTime()
+
+	err = dbClient.ReplaceItem(item.Id, item)
+	if err != nil {
+		return PasteEntry{}, err
+	}
+	return NewPasteEntryFromDbItem(*item), nil
+}
+
 func deletePasteEntry(p PasteEntry) error {
 	// delete it
 	slog.Info("deleting paste", "id", p.Id, "source", "deletePasteEntry")
@@ -60,3 +173,23 @@ func deletePasteEntry(p PasteEntry) error {
 
 	return nil
 }
+
+// deletePasteWithToken deletes a paste for whoever holds its edit token.
+func deletePasteWithToken(id string, editToken string) error {
+	item, err := dbClient.ReadItem(db.ItemID(id))
+	if err != nil || isExpired(*item) {
+		return &readError{http.StatusNotFound, fmt.Sprintf("no paste found with id: %s", id)}
+	}
+	if !editTokenMatches(*item, editToken) {
+		return &readError{http.StatusForbidden, "wrong or missing delete token"}
+	}
+	return deletePasteEntry(NewPasteEntryFromDbItem(*item))
+}
+
+// countPasteView counts a view of the paste and returns the new count. It
+// fails with db.ErrViewLimit when other reads already took the views up to
+// limit, 0 for no limit.
+func countPasteView(p PasteEntry, limit int) (int, error) {
+	slog.Debug("counting paste view", "id", p.Id, "source", "countPasteView")
+	return dbClient.CountView(db.ItemID(p.Id), limit)
+}
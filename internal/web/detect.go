@@ -0,0 +1,141 @@
+package web
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Content type detection sorts pastes into a few broad kinds, which decide
+// how the paste is shown when nobody picked a language.
+
+const (
+	contentTypeText   string = "text"
+	contentTypeJSON   string = "json"
+	contentTypeYAML   string = "yaml"
+	contentTypeShell  string = "shell"
+	contentTypeDiff   string = "diff"
+	contentTypeLog    string = "log"
+	contentTypeBinary string = "binary"
+
+	// only this many lines are looked at for the line based checks
+	detectSampleLines int = 200
+)
+
+var (
+	shebangPattern = regexp.MustCompile(`^#!\s*\S*\b(sh|bash|zsh|ksh|dash|ash)\b`)
+	diffPattern    = regexp.MustCompile(`(?m)^(diff --git |--- \S.*\n\+\+\+ \S)`)
+	hunkPattern    = regexp.MustCompile(`(?m)^@@ -\d+(,\d+)? \+\d+(,\d+)? @@`)
+	logLinePattern = regexp.MustCompile(`^(` +
+		// 2024-01-31 17:00:00, 2024-01-31T17:00:00Z, [2024-01-31 ...
+		`\[?\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}` +
+		// syslog: Jan 31 17:00:00
+		`|[A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}` +
+		// access logs: 10.0.0.1 - - [31/Jan/2024:17:00:00 +0000]
+		`|\S+ \S+ \S+ \[\d{2}/[A-Z][a-z]{2}/\d{4}:\d{2}:\d{2}:\d{2}` +
+		// logfmt
+		`|(time|ts|level)=` +
+		// INFO something, [WARN] something
+		`|\[?(TRACE|DEBUG|INFO|WARN|WARNING|ERROR|FATAL|CRITICAL)\b` +
+		`)`)
+	yamlLinePattern = regexp.MustCompile(`^\s*(- |-$|#|[^\s:][^:]*:(\s|$))`)
+)
+
+// detectContentType sniffs what kind of content a paste is.
+func detectContentType(content string) string {
+	if strings.ContainsRune(content, 0) || !utf8.ValidString(content) {
+		return contentTypeBinary
+	}
+	trimmed := strings.TrimSpace(content)
+	if trimmed == "" {
+		return contentTypeText
+	}
+	if (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid([]byte(trimmed)) {
+		return contentTypeJSON
+	}
+	if hunkPattern.MatchString(content) && diffPattern.MatchString(content) {
+		return contentTypeDiff
+	}
+	if shebangPattern.MatchString(trimmed) {
+		return contentTypeShell
+	}
+
+	lines := sampleLines(trimmed)
+	if len(lines) >= 2 && matchingLines(lines, logLinePattern)*2 >= len(lines) {
+		return contentTypeLog
+	}
+	if len(lines) >= 2 && looksLikeYAML(trimmed, lines) {
+		return contentTypeYAML
+	}
+	return contentTypeText
+}
+
+// sampleLines returns the first non-blank lines of the content.
+func sampleLines(content string) []string {
+	lines := []string{}
+	for _, line := range strings.Split(content, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		lines = append(lines, line)
+		if len(lines) == detectSampleLines {
+			break
+		}
+	}
+	return lines
+}
+
+func matchingLines(lines []string, pattern *regexp.Regexp) int {
+	n := 0
+	for _, line := range lines {
+		if pattern.MatchString(line) {
+			n++
+		}
+	}
+	return n
+}
+
+// looksLikeYAML wants a document that parses to a mapping or list, with
+// most lines shaped like keys or list items. Plenty of prose parses as YAML.
+func looksLikeYAML(content string, lines []string) bool {
+	var doc any
+	if err := yaml.Unmarshal([]byte(content), &doc); err != nil {
+		return false
+	}
+	switch doc.(type) {
+	case map[string]any, []any:
+	default:
+		return false
+	}
+	return matchingLines(lines, yamlLinePattern)*4 >= len(lines)*3
+}
+
+// contentTypeLanguage picks the language to highlight a content type with.
+// Plain text returns "" so the language gets detected instead.
+func contentTypeLanguage(contentType string) string {
+	switch contentType {
+	case contentTypeJSON:
+		return "json"
+	case contentTypeYAML:
+		return "yaml"
+	case contentTypeShell:
+		return "bash"
+	case contentTypeDiff:
+		return "diff"
+	case contentTypeLog, contentTypeBinary:
+		return defaultLanguage
+	}
+	return ""
+}
+
+// defaultLanguageFor returns the language to show content in when nobody
+// picked one.
+func defaultLanguageFor(contentType string, content string) string {
+	if language := contentTypeLanguage(contentType); language != "" {
+		return language
+	}
+	return detectLanguage(content)
+}
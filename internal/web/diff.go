@@ -0,0 +1,407 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lcrownover/duckpaste/internal/db"
+)
+
+const (
+	diffContext int = 3
+	// Diffing takes time in proportion to the lines times the changes, so
+	// both are capped.
+	maxDiffLines int = 20000
+	maxDiffEdits int = 2000
+)
+
+type diffLine struct {
+	// ' ' for unchanged, '-' for removed from a, '+' for added in b
+	Kind  byte
+	Text  string
+	ALine int
+	BLine int
+}
+
+func (l diffLine) Class() string {
+	switch l.Kind {
+	case '-':
+		return "diffRemoved"
+	case '+':
+		return "diffAdded"
+	}
+	return "diffContext"
+}
+
+// diffRow is a line of the side by side view. A side with no line has a
+// zero line number.
+type diffRow struct {
+	Left  diffLine
+	Right diffLine
+}
+
+type diffHunk struct {
+	Header string
+	Lines  []diffLine
+	Rows   []diffRow
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines computes a shortest line edit script from a to b using the
+// linear space variant of Myers' algorithm, which splits the problem at the
+// middle snake of the edit path. Its time grows with the number of edits,
+// so scripts longer than maxDiffEdits are refused.
+func diffLines(a, b []string) ([]diffLine, error) {
+	d := &differ{a: a, b: b}
+	err := d.compare(0, len(a), 0, len(b))
+	if err != nil {
+		return nil, err
+	}
+	// within each run of changes, show what was removed before what
+	// replaced it
+	for i := 0; i < len(d.lines); {
+		if d.lines[i].Kind == ' ' {
+			i++
+			continue
+		}
+		j := i
+		for j < len(d.lines) && d.lines[j].Kind != ' ' {
+			j++
+		}
+		sort.SliceStable(d.lines[i:j], func(x, y int) bool {
+			return d.lines[i+x].Kind == '-' && d.lines[i+y].Kind == '+'
+		})
+		i = j
+	}
+	return d.lines, nil
+}
+
+var errTooManyEdits = fmt.Errorf("pastes differ in too many lines to diff, the limit is %d changed lines", maxDiffEdits)
+
+type differ struct {
+	a, b  []string
+	lines []diffLine
+}
+
+func (d *differ) same(i, j int) {
+	d.lines = append(d.lines, diffLine{Kind: ' ', Text: d.a[i], ALine: i + 1, BLine: j + 1})
+}
+
+// compare appends the edits turning a[aLo:aHi] into b[bLo:bHi].
+func (d *differ) compare(aLo, aHi, bLo, bHi int) error {
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		d.same(aLo, bLo)
+		aLo++
+		bLo++
+	}
+	aEnd, bEnd := aHi, bHi
+	for aLo < aEnd && bLo < bEnd && d.a[aEnd-1] == d.b[bEnd-1] {
+		aEnd--
+		bEnd--
+	}
+
+	switch {
+	case aLo == aEnd:
+		for j := bLo; j < bEnd; j++ {
+			d.lines = append(d.lines, diffLine{Kind: '+', Text: d.b[j], BLine: j + 1})
+		}
+	case bLo == bEnd:
+		for i := aLo; i < aEnd; i++ {
+			d.lines = append(d.lines, diffLine{Kind: '-', Text: d.a[i], ALine: i + 1})
+		}
+	default:
+		x, y, u, v, err := d.middleSnake(aLo, aEnd, bLo, bEnd)
+		if err != nil {
+			return err
+		}
+		err = d.compare(aLo, x, bLo, y)
+		if err != nil {
+			return err
+		}
+		for ; x < u; x, y = x+1, y+1 {
+			d.same(x, y)
+		}
+		err = d.compare(u, aEnd, v, bEnd)
+		if err != nil {
+			return err
+		}
+	}
+
+	for i, j := aEnd, bEnd; i < aHi; i, j = i+1, j+1 {
+		d.same(i, j)
+	}
+	return nil
+}
+
+// middleSnake searches from both ends of a[aLo:aHi] and b[bLo:bHi] at once
+// and returns the diagonal run where the two searches meet, from (x, y) to
+// (u, v). Both searches only keep the furthest point reached on each
+// diagonal, so memory stays linear.
+func (d *differ) middleSnake(aLo, aHi, bLo, bHi int) (int, int, int, int, error) {
+	n, m := aHi-aLo, bHi-bLo
+	delta := n - m
+	odd := delta%2 != 0
+	maxD := (n + m + 1) / 2
+	offset := maxD + 1
+	forward := make([]int, 2*maxD+3)
+	reverse := make([]int, 2*maxD+3)
+
+	for step := 0; step <= maxD; step++ {
+		// the edit script is at least 2*step-1 long from here on
+		if 2*step-1 > maxDiffEdits {
+			return 0, 0, 0, 0, errTooManyEdits
+		}
+		for k := -step; k <= step; k += 2 {
+			var x int
+			if k == -step || (k != step && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+			y := x - k
+			x0, y0 := x, y
+			for x < n && y < m && d.a[aLo+x] == d.b[bLo+y] {
+				x++
+				y++
+			}
+			forward[offset+k] = x
+			// reverse diagonal delta-k was last extended in the previous step
+			if odd && k >= delta-(step-1) && k <= delta+(step-1) && x+reverse[offset+delta-k] >= n {
+				return aLo + x0, bLo + y0, aLo + x, bLo + y, nil
+			}
+		}
+		for k := -step; k <= step; k += 2 {
+			var x int
+			if k == -step || (k != step && reverse[offset+k-1] < reverse[offset+k+1]) {
+				x = reverse[offset+k+1]
+			} else {
+				x = reverse[offset+k-1] + 1
+			}
+			y := x - k
+			x0, y0 := x, y
+			for x < n && y < m && d.a[aHi-1-x] == d.b[bHi-1-y] {
+				x++
+				y++
+			}
+			reverse[offset+k] = x
+			if !odd && k >= delta-step && k <= delta+step && x+forward[offset+delta-k] >= n {
+				return aHi - x, bHi - y, aHi - x0, bHi - y0, nil
+			}
+		}
+	}
+	// the searches always meet by maxD
+	return 0, 0, 0, 0, fmt.Errorf("failed to find the middle snake")
+}
+
+// diffHunks groups the changes with a few lines of context around them.
+func diffHunks(lines []diffLine) []diffHunk {
+	hunks := []diffHunk{}
+	for i := 0; i < len(lines); {
+		if lines[i].Kind == ' ' {
+			i++
+			continue
+		}
+		start := max(0, i-diffContext)
+		// extend the hunk while the next change is close enough to share context
+		end := i
+		for j := i; j < len(lines); j++ {
+			if lines[j].Kind != ' ' {
+				end = j
+			} else if j-end > 2*diffContext {
+				break
+			}
+		}
+		end = min(len(lines), end+diffContext+1)
+		hunks = append(hunks, newDiffHunk(lines[start:end]))
+		i = end
+	}
+	return hunks
+}
+
+func newDiffHunk(lines []diffLine) diffHunk {
+	aStart, bStart, aLen, bLen := 0, 0, 0, 0
+	for _, l := range lines {
+		if l.Kind != '+' {
+			if aStart == 0 {
+				aStart = l.ALine
+			}
+			aLen++
+		}
+		if l.Kind != '-' {
+			if bStart == 0 {
+				bStart = l.BLine
+			}
+			bLen++
+		}
+	}
+	return diffHunk{
+		Header: fmt.Sprintf("@@ -%d,%d +%d,%d @@", aStart, aLen, bStart, bLen),
+		Lines:  lines,
+		Rows:   diffRows(lines),
+	}
+}
+
+// diffRows lines up removals with the additions that replaced them.
+func diffRows(lines []diffLine) []diffRow {
+	rows := []diffRow{}
+	for i := 0; i < len(lines); {
+		if lines[i].Kind == ' ' {
+			rows = append(rows, diffRow{Left: lines[i], Right: lines[i]})
+			i++
+			continue
+		}
+		removed, added := []diffLine{}, []diffLine{}
+		for ; i < len(lines) && lines[i].Kind == '-'; i++ {
+			removed = append(removed, lines[i])
+		}
+		for ; i < len(lines) && lines[i].Kind == '+'; i++ {
+			added = append(added, lines[i])
+		}
+		for j := 0; j < max(len(removed), len(added)); j++ {
+			var row diffRow
+			if j < len(removed) {
+				row.Left = removed[j]
+			}
+			if j < len(added) {
+				row.Right = added[j]
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+func unifiedDiff(aName, bName string, hunks []diffHunk) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- a/%s\n+++ b/%s\n", aName, bName)
+	for _, h := range hunks {
+		sb.WriteString(h.Header + "\n")
+		for _, l := range h.Lines {
+			sb.WriteByte(l.Kind)
+			sb.WriteString(l.Text + "\n")
+		}
+	}
+	return sb.String()
+}
+
+type diffResponse struct {
+	A    string `json:"a"`
+	B    string `json:"b"`
+	Diff string `json:"diff"`
+}
+
+// diffPasswords finds the password for each side. Either can be given on
+// its own, otherwise the usual password applies to both.
+func diffPasswords(c *gin.Context) (string, string) {
+	password := requestPassword(c)
+	a, b := password, password
+	if p := c.GetHeader("X-Paste-Password-A"); p != "" {
+		a = p
+	} else if p := c.PostForm("pastePasswordA"); p != "" {
+		a = p
+	}
+	if p := c.GetHeader("X-Paste-Password-B"); p != "" {
+		b = p
+	} else if p := c.PostForm("pastePasswordB"); p != "" {
+		b = p
+	}
+	return a, b
+}
+
+// openDiff opens both pastes and diffs their content. Neither paste counts
+// as read unless both can be opened.
+func openDiff(c *gin.Context) ([]diffHunk, error) {
+	passwordA, passwordB := diffPasswords(c)
+	a, err := checkPaste(c, c.Param("a"), passwordA)
+	if err != nil {
+		return nil, err
+	}
+	b, err := checkPaste(c, c.Param("b"), passwordB)
+	if err != nil {
+		return nil, err
+	}
+
+	contentA, err := db.DecodeContent(db.ItemContent(a.Content))
+	if err != nil {
+		return nil, err
+	}
+	contentB, err := db.DecodeContent(db.ItemContent(b.Content))
+	if err != nil {
+		return nil, err
+	}
+	linesA, linesB := splitLines(contentA), splitLines(contentB)
+	if len(linesA)+len(linesB) > maxDiffLines {
+		return nil, &readError{http.StatusRequestEntityTooLarge, fmt.Sprintf("pastes are too large to diff, the limit is %d lines", maxDiffLines)}
+	}
+
+	lines, err := diffLines(linesA, linesB)
+	if err == errTooManyEdits {
+		return nil, &readError{http.StatusRequestEntityTooLarge, err.Error()}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = consumePaste(c, a)
+	if err != nil {
+		return nil, err
+	}
+	if b.Id != a.Id {
+		_, err = consumePaste(c, b)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return diffHunks(lines), nil
+}
+
+// getDiffApi handles GET /api/diff/:a/:b with a unified diff.
+func (h *WebHandler) getDiffApi(c *gin.Context) {
+	hunks, err := openDiff(c)
+	if err != nil {
+		c.JSON(readStatus(err), errorResponse{
+			err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, diffResponse{
+		A:    c.Param("a"),
+		B:    c.Param("b"),
+		Diff: unifiedDiff(c.Param("a"), c.Param("b"), hunks),
+	})
+}
+
+// getDiff shows the diff page, unified or with ?view=split side by side.
+func (h *WebHandler) getDiff(c *gin.Context) {
+	hunks, err := openDiff(c)
+	if err != nil {
+		if readStatus(err) == http.StatusUnauthorized {
+			c.HTML(http.StatusUnauthorized, "templates/password.html", gin.H{
+				"action": c.Request.URL.RequestURI(),
+				"retry":  c.PostForm("pastePasswordA") != "" || c.PostForm("pastePasswordB") != "",
+				"fields": []passwordField{
+					{"pastePasswordA", "password for " + c.Param("a")},
+					{"pastePasswordB", "password for " + c.Param("b")},
+				},
+			})
+			return
+		}
+		h.pasteReadError(c, err)
+		return
+	}
+	c.HTML(http.StatusOK, "templates/diff.html", gin.H{
+		"a":     c.Param("a"),
+		"b":     c.Param("b"),
+		"hunks": hunks,
+		"split": c.Query("view") == "split",
+	})
+}
@@ -0,0 +1,150 @@
+package web
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// lcsLength is the textbook quadratic longest common subsequence, to check
+// that diffLines finds a shortest edit script.
+func lcsLength(a, b []string) int {
+	prev := make([]int, len(b)+1)
+	for i := range a {
+		cur := make([]int, len(b)+1)
+		for j := range b {
+			if a[i] == b[j] {
+				cur[j+1] = prev[j] + 1
+			} else {
+				cur[j+1] = max(prev[j+1], cur[j])
+			}
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// checkScript verifies the script rebuilds both sides with correct line
+// numbers and is as short as possible.
+func checkScript(t *testing.T, a, b []string, lines []diffLine) {
+	t.Helper()
+	var gotA, gotB []string
+	edits := 0
+	for _, l := range lines {
+		if l.Kind != '+' {
+			if l.ALine != len(gotA)+1 {
+				t.Fatalf("line %q has ALine %d, want %d", l.Text, l.ALine, len(gotA)+1)
+			}
+			gotA = append(gotA, l.Text)
+		}
+		if l.Kind != '-' {
+			if l.BLine != len(gotB)+1 {
+				t.Fatalf("line %q has BLine %d, want %d", l.Text, l.BLine, len(gotB)+1)
+			}
+			gotB = append(gotB, l.Text)
+		}
+		if l.Kind != ' ' {
+			edits++
+		}
+	}
+	if strings.Join(gotA, "\n") != strings.Join(a, "\n") {
+		t.Fatalf("script doesn't rebuild a: got %q, want %q", gotA, a)
+	}
+	if strings.Join(gotB, "\n") != strings.Join(b, "\n") {
+		t.Fatalf("script doesn't rebuild b: got %q, want %q", gotB, b)
+	}
+	if want := len(a) + len(b) - 2*lcsLength(a, b); edits != want {
+		t.Fatalf("script has %d edits, want %d", edits, want)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{"equal", "a\nb\nc", "a\nb\nc", " a b c"},
+		{"both empty", "", "", ""},
+		{"from empty", "", "a\nb", "+a+b"},
+		{"to empty", "a\nb", "", "-a-b"},
+		{"change in the middle", "a\nb\nc", "a\nx\nc", " a-b+x c"},
+		{"insert at the start", "b\nc", "a\nb\nc", "+a b c"},
+		{"delete at the end", "a\nb\nc", "a\nb", " a b-c"},
+		{"replace all", "a\nb", "x\ny", "-a-b+x+y"},
+		{"move", "a\nb\nc", "b\nc\na", "-a b c+a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := splitLines(tt.a), splitLines(tt.b)
+			lines, err := diffLines(a, b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got strings.Builder
+			for _, l := range lines {
+				got.WriteByte(l.Kind)
+				got.WriteString(l.Text)
+			}
+			if got.String() != tt.want {
+				t.Errorf("got %q, want %q", got.String(), tt.want)
+			}
+			checkScript(t, a, b, lines)
+		})
+	}
+}
+
+func TestDiffLinesRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	randomLines := func() []string {
+		lines := make([]string, rng.Intn(40))
+		for i := range lines {
+			// a small alphabet makes for plenty of matches
+			lines[i] = string(rune('a' + rng.Intn(4)))
+		}
+		return lines
+	}
+	for i := 0; i < 500; i++ {
+		a, b := randomLines(), randomLines()
+		lines, err := diffLines(a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkScript(t, a, b, lines)
+	}
+}
+
+func TestDiffLinesLimits(t *testing.T) {
+	numbered := func(prefix string, n int) []string {
+		lines := make([]string, n)
+		for i := range lines {
+			lines[i] = fmt.Sprintf("%s%d", prefix, i)
+		}
+		return lines
+	}
+
+	// large but similar pastes are fine
+	a := numbered("line ", maxDiffLines/2)
+	b := append([]string{}, a...)
+	for i := 0; i < len(b); i += 100 {
+		b[i] = "changed"
+	}
+	lines, err := diffLines(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != len(a)+len(a)/100 {
+		t.Errorf("got %d lines, want %d", len(lines), len(a)+len(a)/100)
+	}
+
+	// pastes with nothing in common need an edit per line
+	_, err = diffLines(numbered("a", maxDiffEdits), numbered("b", 1))
+	if err != errTooManyEdits {
+		t.Errorf("got %v, want errTooManyEdits", err)
+	}
+	_, err = diffLines(numbered("a", maxDiffEdits-1), numbered("b", 1))
+	if err != nil {
+		t.Errorf("an edit script of exactly maxDiffEdits failed: %v", err)
+	}
+}
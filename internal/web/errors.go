@@ -1,5 +1,70 @@
 package web
 
+import (
+	"github.com/gin-gonic/gin"
+)
+
 type errorResponse struct {
 	Message string `json:"message"`
 }
+
+// set on requests to the versioned API, which only ever answers in JSON
+const jsonAPIKey string = "jsonAPI"
+
+func jsonAPI(c *gin.Context) {
+	c.Set(jsonAPIKey, true)
+	c.Next()
+}
+
+// set on plain uploads, which answer with a line of text
+const plainTextKey string = "plainText"
+
+func plainText(c *gin.Context) {
+	c.Set(plainTextKey, true)
+	c.Next()
+}
+
+// set on the routes browsers load as pages, which answer errors with a page
+const htmlPageKey string = "htmlPage"
+
+func htmlPage(c *gin.Context) {
+	c.Set(htmlPageKey, true)
+	c.Next()
+}
+
+// wantsPage reports whether the request is a browser loading a page or
+// submitting a form, which expects pages and redirects rather than JSON.
+func wantsPage(c *gin.Context) bool {
+	if c.GetBool(jsonAPIKey) {
+		return false
+	}
+	if c.GetBool(htmlPageKey) {
+		return true
+	}
+	switch c.ContentType() {
+	case gin.MIMEPOSTForm, gin.MIMEMultipartPOSTForm:
+		return c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML
+	}
+	return false
+}
+
+// abortWithError answers with an error page for form submits, a line of
+// text for plain uploads and with JSON for everything else.
+func abortWithError(c *gin.Context, status int, message string) {
+	if c.GetBool(plainTextKey) {
+		c.String(status, "%s\n", message)
+		c.Abort()
+		return
+	}
+	if wantsPage(c) {
+		c.HTML(status, "templates/error.html", gin.H{
+			"status":  status,
+			"message": message,
+		})
+		c.Abort()
+		return
+	}
+	c.AbortWithStatusJSON(status, errorResponse{
+		message,
+	})
+}
@@ -0,0 +1,180 @@
+package web
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Pastes expire after a duration like 90m, 36h, 3d or 2w, at an absolute
+// RFC3339 time, or never when the server allows it.
+
+const (
+	defaultLifetime    time.Duration = 48 * time.Hour
+	defaultMaxLifetime time.Duration = 30 * 24 * time.Hour
+	minLifetime        time.Duration = time.Minute
+	neverExpire        string        = "never"
+)
+
+// ExpirationLimits is how long pastes may live.
+type ExpirationLimits struct {
+	Default    time.Duration
+	Max        time.Duration
+	AllowNever bool
+}
+
+// Days and weeks have to be whole numbers. The pattern takes in any
+// fraction so 1.5d is refused rather than read as 1.120h.
+var daysWeeksPattern = regexp.MustCompile(`(\d*\.?\d+)([dw])`)
+
+// maxHours is the most hours a time.Duration can hold.
+const maxHours int64 = math.MaxInt64 / int64(time.Hour)
+
+// hoursDuration converts hours to a duration, failing where it would
+// overflow.
+func hoursDuration(hours int64) (time.Duration, bool) {
+	if hours > maxHours || hours < -maxHours {
+		return 0, false
+	}
+	return time.Duration(hours) * time.Hour, true
+}
+
+// parseLifetime parses a duration, adding d and w to the units Go knows. A
+// bare number is hours, like the old expiration dropdown sent.
+func parseLifetime(s string) (time.Duration, error) {
+	if hours, err := strconv.ParseInt(s, 10, 64); err == nil {
+		d, ok := hoursDuration(hours)
+		if !ok {
+			return 0, fmt.Errorf("invalid expiration: %s", s)
+		}
+		return d, nil
+	}
+	valid := true
+	expanded := daysWeeksPattern.ReplaceAllStringFunc(s, func(m string) string {
+		parts := daysWeeksPattern.FindStringSubmatch(m)
+		n, err := strconv.ParseInt(parts[1], 10, 64)
+		days := int64(1)
+		if parts[2] == "w" {
+			days = 7
+		}
+		if err != nil || n > maxHours/(24*days) {
+			valid = false
+			return m
+		}
+		return fmt.Sprintf("%dh", n*24*days)
+	})
+	d, err := time.ParseDuration(expanded)
+	if err != nil || !valid {
+		return 0, fmt.Errorf("invalid expiration: %s", s)
+	}
+	return d, nil
+}
+
+// resolveExpiry works out when a paste created at now expires, from the
+// expiration string or the older expirationHours. It returns nil for a paste
+// that never expires.
+func resolveExpiry(expiration string, hours int, now time.Time, limits ExpirationLimits) (*time.Time, error) {
+	expiration = strings.TrimSpace(expiration)
+	var expires time.Time
+	switch {
+	case strings.EqualFold(expiration, neverExpire):
+		if !limits.AllowNever {
+			return nil, fmt.Errorf("pastes that never expire are not allowed on this server")
+		}
+		return nil, nil
+	case expiration != "":
+		if t, err := time.Parse(time.RFC3339, expiration); err == nil {
+			expires = t.UTC()
+			break
+		}
+		lifetime, err := parseLifetime(expiration)
+		if err != nil {
+			return nil, err
+		}
+		expires = now.Add(lifetime)
+	case hours < 0:
+		return nil, fmt.Errorf("expirationHours can't be negative")
+	case hours > 0:
+		lifetime, ok := hoursDuration(int64(hours))
+		if !ok {
+			return nil, fmt.Errorf("expirationHours is too large")
+		}
+		expires = now.Add(lifetime)
+	default:
+		expires = now.Add(limits.Default)
+	}
+
+	lifetime := expires.Sub(now)
+	if lifetime < minLifetime {
+		return nil, fmt.Errorf("expiration must be at least %s in the future", formatLifetime(minLifetime))
+	}
+	if limits.Max > 0 && lifetime > limits.Max {
+		return nil, fmt.Errorf("expiration can't be more than %s in the future", formatLifetime(limits.Max))
+	}
+	return &expires, nil
+}
+
+// lifetimeHours rounds the time until expiry up to whole hours, for the
+// lifetimeHours field older code reads.
+func lifetimeHours(created time.Time, expires *time.Time) int {
+	if expires == nil {
+		return 0
+	}
+	return int(math.Ceil(expires.Sub(created).Hours()))
+}
+
+// formatLifetime shows a duration in the largest unit that fits it exactly.
+func formatLifetime(d time.Duration) string {
+	day := 24 * time.Hour
+	switch {
+	case d%(7*day) == 0:
+		return fmt.Sprintf("%dw", d/(7*day))
+	case d%day == 0:
+		return fmt.Sprintf("%dd", d/day)
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+	return d.String()
+}
+
+func getLifetime(env string, def time.Duration) (time.Duration, error) {
+	s, found := os.LookupEnv(env)
+	if !found {
+		return def, nil
+	}
+	d, err := parseLifetime(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%s: invalid duration: %s", env, s)
+	}
+	return d, nil
+}
+
+// getExpirationLimits reads DEFAULT_LIFETIME, MAX_LIFETIME (0 for no limit)
+// and ALLOW_NEVER_EXPIRE.
+func getExpirationLimits() (ExpirationLimits, error) {
+	def, err := getLifetime("DEFAULT_LIFETIME", defaultLifetime)
+	if err != nil {
+		return ExpirationLimits{}, err
+	}
+	maxLifetime, err := getLifetime("MAX_LIFETIME", defaultMaxLifetime)
+	if err != nil {
+		return ExpirationLimits{}, err
+	}
+	if def < minLifetime || (maxLifetime > 0 && def > maxLifetime) {
+		return ExpirationLimits{}, fmt.Errorf("DEFAULT_LIFETIME must be between %s and MAX_LIFETIME", formatLifetime(minLifetime))
+	}
+	allowNever := false
+	if s, found := os.LookupEnv("ALLOW_NEVER_EXPIRE"); found {
+		allowNever, err = strconv.ParseBool(s)
+		if err != nil {
+			return ExpirationLimits{}, fmt.Errorf("ALLOW_NEVER_EXPIRE: %v", err)
+		}
+	}
+	return ExpirationLimits{Default: def, Max: maxLifetime, AllowNever: allowNever}, nil
+}
@@ -0,0 +1,116 @@
+package web
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLifetime(t *testing.T) {
+	day := 24 * time.Hour
+	tests := []struct {
+		s    string
+		want time.Duration
+		ok   bool
+	}{
+		{"90m", 90 * time.Minute, true},
+		{"36h", 36 * time.Hour, true},
+		{"3d", 3 * day, true},
+		{"2w", 14 * day, true},
+		{"1w2d", 9 * day, true},
+		{"1d12h", 36 * time.Hour, true},
+		{"1h30m", 90 * time.Minute, true},
+		{"1.5h", 90 * time.Minute, true},
+		{"24", day, true},
+		{"0", 0, true},
+		{"-5", -5 * time.Hour, true},
+
+		{"", 0, false},
+		{"soon", 0, false},
+		{"1.5d", 0, false},
+		{"0.5w", 0, false},
+		{"1.1d", 0, false},
+		{".5d", 0, false},
+		{"1.d", 0, false},
+		{"1h.5d", 0, false},
+		{"d", 0, false},
+		{"3x", 0, false},
+		{"9999999999", 0, false},
+		{"-9999999999", 0, false},
+		{"99999999999999999999", 0, false},
+		{"999999999999d", 0, false},
+		{"99999999999w", 0, false},
+		{"999999999999999999999d", 0, false},
+		{"9999999999h", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := parseLifetime(tt.s)
+			if !tt.ok {
+				if err == nil {
+					t.Errorf("parseLifetime(%q) = %s, want an error", tt.s, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseLifetime(%q) failed: %v", tt.s, err)
+			}
+			if got != tt.want {
+				t.Errorf("parseLifetime(%q) = %s, want %s", tt.s, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveExpiry(t *testing.T) {
+	now := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	limits := ExpirationLimits{Default: 48 * time.Hour, Max: 30 * 24 * time.Hour}
+	unlimited := ExpirationLimits{Default: 48 * time.Hour, AllowNever: true}
+	tests := []struct {
+		name       string
+		expiration string
+		hours      int
+		limits     ExpirationLimits
+		want       time.Duration // from now, -1 for never
+		ok         bool
+	}{
+		{"default", "", 0, limits, 48 * time.Hour, true},
+		{"duration", "3d", 0, limits, 72 * time.Hour, true},
+		{"hours", "", 5, limits, 5 * time.Hour, true},
+		{"absolute", "2024-01-03T15:04:05Z", 0, limits, 24 * time.Hour, true},
+		{"never", "never", 0, unlimited, -1, true},
+		{"no limit", "52w", 0, unlimited, 52 * 7 * 24 * time.Hour, true},
+
+		{"never not allowed", "never", 0, limits, 0, false},
+		{"too short", "30s", 0, limits, 0, false},
+		{"over the limit", "31d", 0, limits, 0, false},
+		{"fractional days", "1.5d", 0, limits, 0, false},
+		{"negative hours", "", -1, limits, 0, false},
+		{"in the past", "2024-01-01T00:00:00Z", 0, limits, 0, false},
+		{"overflowing duration", "9999999999", 0, unlimited, 0, false},
+		{"overflowing days", "999999999999d", 0, unlimited, 0, false},
+		{"overflowing hours", "", int(maxHours) + 1, unlimited, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveExpiry(tt.expiration, tt.hours, now, tt.limits)
+			if !tt.ok {
+				if err == nil {
+					t.Errorf("got %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want < 0 {
+				if got != nil {
+					t.Errorf("got %v, want never", got)
+				}
+				return
+			}
+			if got == nil || got.Sub(now) != tt.want {
+				t.Errorf("got %v, want %s from now", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package web
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lcrownover/duckpaste/internal/db"
+)
+
+const maxFiles int = 20
+
+// FileEntry is one named file in a multi-file paste. Like PasteEntry.Content,
+// Content is plain text going in and encoded coming out of the database.
+type FileEntry struct {
+	Name     string `json:"name"`
+	Language string `json:"language"`
+	Content  string `json:"content"`
+}
+
+func NewFileEntriesFromDbItem(item db.Item) []FileEntry {
+	entries := []FileEntry{}
+	for _, f := range item.Files {
+		entries = append(entries, FileEntry{
+			Name:     f.Name,
+			Language: f.Language,
+			Content:  string(f.Content),
+		})
+	}
+	return entries
+}
+
+func NewDbFiles(files []FileEntry) []db.ItemFile {
+	dbFiles := []db.ItemFile{}
+	for _, f := range files {
+		dbFiles = append(dbFiles, db.ItemFile{
+			Name:     f.Name,
+			Language: f.Language,
+			Content:  db.EncodeContent(f.Content),
+		})
+	}
+	return dbFiles
+}
+
+// readFormFiles collects the files added with the dynamic form, which posts
+// parallel pasteFileName, pasteFileLanguage and pasteFileContent fields.
+func readFormFiles(c *gin.Context) []FileEntry {
+	names := c.PostFormArray("pasteFileName")
+	languages := c.PostFormArray("pasteFileLanguage")
+	contents := c.PostFormArray("pasteFileContent")
+
+	files := []FileEntry{}
+	for i, content := range contents {
+		f := FileEntry{Content: content}
+		if i < len(names) {
+			f.Name = names[i]
+		}
+		if i < len(languages) {
+			f.Language = languages[i]
+		}
+		// the form always posts its rows, even blank ones
+		if strings.TrimSpace(f.Content) == "" && f.Name == "" {
+			continue
+		}
+		files = append(files, f)
+	}
+	return files
+}
+
+// validFileName reports whether the name is safe to use as is, including as
+// a zip entry: no directories on any system, no drive letters and no dot
+// names.
+func validFileName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\:`)
+}
+
+// normalizeFiles validates the files of a new paste, naming unnamed files
+// and detecting languages that weren't given.
+func normalizeFiles(files []FileEntry) ([]FileEntry, error) {
+	if len(files) > maxFiles {
+		return nil, fmt.Errorf("too many files, the limit is %d", maxFiles)
+	}
+	seen := map[string]bool{}
+	normalized := []FileEntry{}
+	for i, f := range files {
+		if strings.TrimSpace(f.Content) == "" {
+			return nil, fmt.Errorf("file %d has no content", i+1)
+		}
+		language, err := normalizeLanguage(f.Language)
+		if err != nil {
+			return nil, err
+		}
+		if language == "" {
+			language = defaultLanguageFor(detectContentType(f.Content), f.Content)
+		}
+		name := strings.TrimSpace(f.Name)
+		if name == "" {
+			name = fmt.Sprintf("file%d%s", i+1, languageExtension(language))
+		}
+		if !validFileName(name) {
+			return nil, fmt.Errorf("invalid file name %q, names can't contain /, \\ or : or be . or ..", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate file name: %s", name)
+		}
+		seen[name] = true
+		normalized = append(normalized, FileEntry{Name: name, Language: language, Content: f.Content})
+	}
+	return normalized, nil
+}
+
+var anchorUnsafe = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+func fileAnchor(name string) string {
+	return "file-" + strings.Trim(anchorUnsafe.ReplaceAllString(name, "-"), "-")
+}
+
+// fileBlock is a file as shown on the paste page.
+type fileBlock struct {
+	Name        string
+	Anchor      string
+	Language    string
+	Content     string
+	Highlighted template.HTML
+}
+
+func fileBlocks(paste PasteEntry) []fileBlock {
+	blocks := []fileBlock{}
+	for _, f := range paste.Files {
+		content, err := db.DecodeContent(db.ItemContent(f.Content))
+		if err != nil {
+			slog.Error("failed to decode file: "+err.Error(), "id", paste.Id, "file", f.Name, "source", "fileBlocks")
+			continue
+		}
+		highlighted, err := highlight(content, f.Language, fileAnchor(f.Name)+"-L", 1)
+		if err != nil {
+			slog.Error("failed to highlight file: "+err.Error(), "id", paste.Id, "file", f.Name, "source", "fileBlocks")
+			highlighted = template.HTML("<pre><code>" + template.HTMLEscapeString(content) + "</code></pre>")
+		}
+		blocks = append(blocks, fileBlock{
+			Name:        f.Name,
+			Anchor:      fileAnchor(f.Name),
+			Language:    f.Language,
+			Content:     content,
+			Highlighted: highlighted,
+		})
+	}
+	return blocks
+}
+
+// getZip downloads the paste content and all of its files as a zip.
+func (h *WebHandler) getZip(c *gin.Context) {
+	paste, err := openPaste(c, c.Param("pasteId"))
+	if err != nil {
+		c.String(readStatus(err), err.Error()+"\n")
+		return
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	add := func(name string, encoded string) error {
+		content, err := db.DecodeContent(db.ItemContent(encoded))
+		if err != nil {
+			return err
+		}
+		w, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write([]byte(content))
+		return err
+	}
+
+	if paste.Content != "" {
+		err = add(paste.Id+languageExtension(paste.Language), paste.Content)
+	}
+	for i, f := range paste.Files {
+		if err != nil {
+			break
+		}
+		// pastes from before names were checked may have unsafe ones
+		name := f.Name
+		if !validFileName(name) {
+			name = fmt.Sprintf("file%d%s", i+1, languageExtension(f.Language))
+		}
+		err = add(name, f.Content)
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		c.String(http.StatusInternalServerError, fmt.Sprintf("failed to build zip: %s\n", err))
+		return
+	}
+
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": paste.Id + ".zip"}))
+	c.Data(http.StatusOK, "application/zip", buf.Bytes())
+}
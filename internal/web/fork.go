@@ -0,0 +1,80 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lcrownover/duckpaste/internal/db"
+)
+
+// Forking opens the form prefilled with another paste's content. The new
+// paste remembers where it came from.
+
+var pasteIDPattern = regexp.MustCompile(`^[A-Za-z0-9+=_-]{1,64}$`)
+
+type forkResponse struct {
+	ForkOf   string `json:"forkOf"`
+	Content  string `json:"content"`
+	Language string `json:"language"`
+	Format   string `json:"format"`
+}
+
+func validateForkOf(forkOf string) error {
+	if forkOf != "" && !pasteIDPattern.MatchString(forkOf) {
+		return fmt.Errorf("invalid paste id to fork from: %s", forkOf)
+	}
+	return nil
+}
+
+// forkPasteApi handles POST /api/paste/:pasteId/fork
+func (h *WebHandler) forkPasteApi(c *gin.Context) {
+	pasteID := c.Param("pasteId")
+	paste, err := openPaste(c, pasteID)
+	if err != nil {
+		if !h.wantsForm(c) {
+			c.JSON(readStatus(err), errorResponse{
+				err.Error(),
+			})
+			return
+		}
+		h.pasteReadError(c, err)
+		return
+	}
+	content, err := db.DecodeContent(db.ItemContent(paste.Content))
+	if err != nil {
+		abortWithError(c, http.StatusInternalServerError, "failed to decode content of paste")
+		return
+	}
+
+	fork := forkResponse{
+		ForkOf:   paste.Id,
+		Content:  content,
+		Language: paste.Language,
+		Format:   paste.Format,
+	}
+	if !h.wantsForm(c) {
+		c.JSON(http.StatusOK, fork)
+		return
+	}
+	h.renderForm(c, fork)
+}
+
+// wantsForm reports whether the fork should open the form rather than answer
+// with JSON. Browsers ask for HTML; the versioned API always gets JSON.
+func (h *WebHandler) wantsForm(c *gin.Context) bool {
+	return !c.GetBool(jsonAPIKey) && c.NegotiateFormat(gin.MIMEHTML, gin.MIMEJSON) == gin.MIMEHTML
+}
+
+// forkedFrom returns the parent of the paste if it still exists.
+func forkedFrom(paste PasteEntry) string {
+	if paste.ForkOf == "" {
+		return ""
+	}
+	exists, err := dbClient.ItemExists(db.ItemID(paste.ForkOf))
+	if err != nil || !exists {
+		return ""
+	}
+	return paste.ForkOf
+}
@@ -0,0 +1,116 @@
+package web
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lcrownover/duckpaste/internal/audit"
+	"github.com/lcrownover/duckpaste/internal/db"
+)
+
+// Editor plugins and command line tools written for hastebin post to
+// /documents and read /raw/:key. With HASTEBIN_COMPAT set those routes
+// create and read ordinary pastes, so the tools work unchanged. Pastes made
+// this way get the default expiration.
+
+type hastebinKey struct {
+	Key string `json:"key"`
+}
+
+type hastebinDocument struct {
+	Key  string `json:"key"`
+	Data string `json:"data"`
+}
+
+// getHastebinCompat reads HASTEBIN_COMPAT, off unless set.
+func getHastebinCompat() (bool, error) {
+	s, found := os.LookupEnv("HASTEBIN_COMPAT")
+	if !found {
+		return false, nil
+	}
+	enabled, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, fmt.Errorf("HASTEBIN_COMPAT: %v", err)
+	}
+	return enabled, nil
+}
+
+// readDocument reads the raw body, or the data field of a multipart form
+// like the hastebin server accepts. Tools that post with curl's default form
+// content type still send the raw text, so that isn't parsed as a form.
+func readDocument(c *gin.Context) (string, error) {
+	if c.ContentType() == gin.MIMEMultipartPOSTForm {
+		form, err := c.MultipartForm()
+		if err != nil || len(form.Value["data"]) == 0 {
+			return "", err
+		}
+		return form.Value["data"][0], nil
+	}
+	data, err := io.ReadAll(c.Request.Body)
+	return string(data), err
+}
+
+// createDocument handles POST /documents
+func (h *WebHandler) createDocument(c *gin.Context) {
+	content, err := readDocument(c)
+	if isTooLarge(err) {
+		abortTooLarge(c, h.sizeLimits(c).MaxBodyBytes)
+		return
+	}
+	if err != nil {
+		abortWithError(c, http.StatusBadRequest, fmt.Sprintf("failed to read document: %s", err))
+		return
+	}
+	if strings.TrimSpace(content) == "" {
+		abortWithError(c, http.StatusBadRequest, "please provide actual content")
+		return
+	}
+
+	paste := PasteEntry{Content: content}
+	if !checkContentSize(c, paste) {
+		return
+	}
+	paste.Expires, err = resolveExpiry("", 0, db.GetCurrentTime(), h.config.Expiration)
+	if err != nil {
+		abortWithError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	paste.owner = c.GetString(apiUserKey)
+
+	paste, err = createPasteEntry(paste, nil)
+	if err != nil {
+		recordEvent(c, audit.ActionCreate, paste.Id, audit.OutcomeFailure, err.Error())
+		abortWithError(c, http.StatusInternalServerError, fmt.Sprintf("failed to create paste entry: %s", err))
+		return
+	}
+	recordEvent(c, audit.ActionCreate, paste.Id, audit.OutcomeSuccess, "")
+	c.Header("X-Edit-Token", paste.EditToken)
+	c.JSON(http.StatusOK, hastebinKey{paste.Id})
+}
+
+// getDocument handles GET /documents/:pasteId
+func (h *WebHandler) getDocument(c *gin.Context) {
+	paste, err := openPaste(c, c.Param("pasteId"))
+	if err != nil {
+		c.JSON(readStatus(err), errorResponse{
+			err.Error(),
+		})
+		return
+	}
+	content, err := db.DecodeContent(db.ItemContent(paste.Content))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse{
+			"failed to decode content of paste",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, hastebinDocument{
+		Key:  paste.Id,
+		Data: content,
+	})
+}
@@ -0,0 +1,183 @@
+package web
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultTheme    string = "monokai"
+	defaultLanguage string = "plaintext"
+)
+
+// Language is an entry in the language dropdown on the form. Any lexer chroma
+// knows about is accepted through the API, these are just the common ones.
+type Language struct {
+	Name  string
+	Label string
+}
+
+var formLanguages = []Language{
+	{"plaintext", "plain text"},
+	{"bash", "bash"},
+	{"powershell", "powershell"},
+	{"python", "python"},
+	{"go", "go"},
+	{"javascript", "javascript"},
+	{"typescript", "typescript"},
+	{"json", "json"},
+	{"yaml", "yaml"},
+	{"toml", "toml"},
+	{"ini", "ini"},
+	{"xml", "xml"},
+	{"html", "html"},
+	{"css", "css"},
+	{"sql", "sql"},
+	{"diff", "diff"},
+	{"docker", "dockerfile"},
+	{"nginx", "nginx"},
+	{"c", "c"},
+	{"cpp", "c++"},
+	{"java", "java"},
+	{"rust", "rust"},
+	{"ruby", "ruby"},
+	{"perl", "perl"},
+	{"php", "php"},
+	{"markdown", "markdown"},
+}
+
+// normalizeLanguage maps a user supplied language to the name of a chroma
+// lexer. An empty language stays empty so it can be detected.
+func normalizeLanguage(language string) (string, error) {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if language == "" {
+		return "", nil
+	}
+	lexer := lexers.Get(language)
+	if lexer == nil {
+		return "", fmt.Errorf("unknown language: %s", language)
+	}
+	return lexerName(lexer), nil
+}
+
+// detectLanguage guesses the language of the content, falling back to
+// plain text when chroma isn't confident.
+func detectLanguage(content string) string {
+	lexer := lexers.Analyse(content)
+	if lexer == nil {
+		return defaultLanguage
+	}
+	return lexerName(lexer)
+}
+
+func lexerName(lexer chroma.Lexer) string {
+	cfg := lexer.Config()
+	if len(cfg.Aliases) > 0 {
+		return cfg.Aliases[0]
+	}
+	return strings.ToLower(cfg.Name)
+}
+
+// languageExtension returns a file extension for the language, for naming
+// downloads.
+func languageExtension(language string) string {
+	lexer := lexers.Get(language)
+	if lexer == nil || language == defaultLanguage {
+		return ".txt"
+	}
+	for _, pattern := range lexer.Config().Filenames {
+		ext := path.Ext(pattern)
+		if strings.HasPrefix(pattern, "*.") && !strings.ContainsAny(ext, "*?[") {
+			return ext
+		}
+	}
+	return ".txt"
+}
+
+// validTheme returns the theme if chroma knows it, otherwise the fallback.
+func validTheme(theme, fallback string) string {
+	if _, ok := styles.Registry[theme]; ok {
+		return theme
+	}
+	return fallback
+}
+
+func themeNames() []string {
+	return styles.Names()
+}
+
+// highlightFormatter marks tokens with classes, leaving the colours to the
+// theme stylesheet, so the theme can be switched in the browser without
+// loading the paste again.
+func highlightFormatter(options ...html.Option) *html.Formatter {
+	return html.New(append([]html.Option{html.WithClasses(true), html.TabWidth(4)}, options...)...)
+}
+
+// themeCSS is the stylesheet for highlighted code in the given theme.
+func themeCSS(theme string) ([]byte, error) {
+	var buf bytes.Buffer
+	err := highlightFormatter().WriteCSS(&buf, styles.Get(theme))
+	if err != nil {
+		return nil, fmt.Errorf("failed to write css for theme %s: %v", theme, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// getThemeCSS serves the stylesheet for a theme at /themes/<theme>.css
+func (h *WebHandler) getThemeCSS(c *gin.Context) {
+	theme, ok := strings.CutSuffix(c.Param("theme"), ".css")
+	if !ok || validTheme(theme, "") == "" {
+		c.String(http.StatusNotFound, "no theme found with name: %s\n", theme)
+		return
+	}
+	css, err := themeCSS(theme)
+	if err != nil {
+		slog.Error(err.Error(), "source", "getThemeCSS")
+		c.String(http.StatusInternalServerError, "failed to build theme\n")
+		return
+	}
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.Data(http.StatusOK, "text/css; charset=utf-8", css)
+}
+
+// highlight renders the content as highlighted HTML. Lines are numbered from
+// firstLine, and each number links to an anchor made of the prefix and line
+// number, e.g. #L12.
+func highlight(content, language, linePrefix string, firstLine int) (template.HTML, error) {
+	lexer := lexers.Get(language)
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	lexer = chroma.Coalesce(lexer)
+
+	formatter := highlightFormatter(
+		html.WithLineNumbers(true),
+		html.WithLinkableLineNumbers(true, linePrefix),
+		html.BaseLineNumber(firstLine),
+	)
+
+	iterator, err := lexer.Tokenise(nil, content)
+	if err != nil {
+		return "", fmt.Errorf("failed to tokenise content: %v", err)
+	}
+
+	var buf bytes.Buffer
+	err = formatter.Format(&buf, styles.Fallback, iterator)
+	if err != nil {
+		return "", fmt.Errorf("failed to format content: %v", err)
+	}
+
+	// chroma escapes the content itself
+	return template.HTML(buf.String()), nil
+}
@@ -0,0 +1,233 @@
+package web
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+const (
+	// Images larger than this in either direction are refused before they
+	// are decoded, which keeps decompression bombs out. For animated gifs
+	// maxImagePixels counts the pixels of every frame.
+	maxImageDimension int = 8192
+	maxImagePixels    int = 32 * 1024 * 1024
+	thumbnailSize     int = 256
+)
+
+var imageMimeTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+func isImageMimeType(mimeType string) bool {
+	return imageMimeTypes[mimeType]
+}
+
+// processedImage is an uploaded image after cleaning.
+type processedImage struct {
+	Name      string
+	MimeType  string
+	Data      []byte
+	Width     int
+	Height    int
+	Thumbnail []byte
+}
+
+// processImage checks that the upload really is an image of the type it
+// claims and re-encodes it. Re-encoding drops EXIF and any other metadata,
+// along with anything smuggled in after the image data. WebP can only be
+// decoded, so it's stored as PNG.
+func processImage(name, mimeType string, data []byte) (*processedImage, error) {
+	cfg, format, err := decodeImageConfig(mimeType, data)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a valid image: %v", name, err)
+	}
+	if cfg.Width > maxImageDimension || cfg.Height > maxImageDimension || cfg.Width*cfg.Height > maxImagePixels {
+		return nil, fmt.Errorf("%s is too large, images can be at most %dx%d", name, maxImageDimension, maxImageDimension)
+	}
+
+	var buf bytes.Buffer
+	var first image.Image
+	switch format {
+	case "gif":
+		// keep every frame of animated gifs, as long as they add up to no
+		// more than a still image could have
+		pixels, err := gifFramePixels(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a valid image: %v", name, err)
+		}
+		if pixels > maxImagePixels {
+			return nil, fmt.Errorf("%s has too many frames, animated images can have at most %d pixels in all", name, maxImagePixels)
+		}
+		g, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a valid image: %v", name, err)
+		}
+		g.Config = image.Config{}
+		err = gif.EncodeAll(&buf, g)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %v", name, err)
+		}
+		first = g.Image[0]
+	case "jpeg":
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a valid image: %v", name, err)
+		}
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90})
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %v", name, err)
+		}
+		first = img
+	default:
+		var img image.Image
+		if format == "webp" {
+			img, err = webp.Decode(bytes.NewReader(data))
+			name = strings.TrimSuffix(name, filepath.Ext(name)) + ".png"
+			mimeType = "image/png"
+		} else {
+			img, err = png.Decode(bytes.NewReader(data))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a valid image: %v", name, err)
+		}
+		err = png.Encode(&buf, img)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %v", name, err)
+		}
+		first = img
+	}
+
+	thumbnail, err := makeThumbnail(first)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make thumbnail for %s: %v", name, err)
+	}
+
+	return &processedImage{
+		Name:      name,
+		MimeType:  mimeType,
+		Data:      buf.Bytes(),
+		Width:     cfg.Width,
+		Height:    cfg.Height,
+		Thumbnail: thumbnail,
+	}, nil
+}
+
+// decodeImageConfig reads just the image header, using the decoder for the
+// detected type so a file can't pass as one type and decode as another.
+func decodeImageConfig(mimeType string, data []byte) (image.Config, string, error) {
+	r := bytes.NewReader(data)
+	switch mimeType {
+	case "image/png":
+		cfg, err := png.DecodeConfig(r)
+		return cfg, "png", err
+	case "image/jpeg":
+		cfg, err := jpeg.DecodeConfig(r)
+		return cfg, "jpeg", err
+	case "image/gif":
+		cfg, err := gif.DecodeConfig(r)
+		return cfg, "gif", err
+	case "image/webp":
+		cfg, err := webp.DecodeConfig(r)
+		return cfg, "webp", err
+	}
+	return image.Config{}, "", fmt.Errorf("unsupported image type %s", mimeType)
+}
+
+// gifFramePixels adds up the size of every frame in a gif by walking its
+// blocks, without decoding any image data.
+func gifFramePixels(data []byte) (int, error) {
+	errTruncated := fmt.Errorf("gif is truncated")
+	if len(data) < 13 {
+		return 0, errTruncated
+	}
+	// header and logical screen descriptor
+	pos := 13
+	if data[10]&0x80 != 0 {
+		pos += 3 << (data[10]&0x07 + 1)
+	}
+	// skipSubBlocks moves past a run of data sub-blocks and its terminator
+	skipSubBlocks := func() bool {
+		for pos < len(data) {
+			size := int(data[pos])
+			pos += 1 + size
+			if size == 0 {
+				return true
+			}
+		}
+		return false
+	}
+
+	pixels := 0
+	for pos < len(data) {
+		switch data[pos] {
+		case 0x21: // extension: label, then sub-blocks
+			pos += 2
+			if !skipSubBlocks() {
+				return 0, errTruncated
+			}
+		case 0x2c: // image descriptor
+			if pos+10 > len(data) {
+				return 0, errTruncated
+			}
+			w := int(data[pos+5]) | int(data[pos+6])<<8
+			h := int(data[pos+7]) | int(data[pos+8])<<8
+			pixels += w * h
+			flags := data[pos+9]
+			pos += 10
+			if flags&0x80 != 0 {
+				pos += 3 << (flags&0x07 + 1)
+			}
+			// LZW minimum code size, then the image data
+			pos++
+			if !skipSubBlocks() {
+				return 0, errTruncated
+			}
+		case 0x3b: // trailer
+			return pixels, nil
+		default:
+			return 0, fmt.Errorf("unknown gif block 0x%02x", data[pos])
+		}
+	}
+	return 0, errTruncated
+}
+
+// makeThumbnail scales the image to fit in a thumbnailSize square as a PNG.
+func makeThumbnail(img image.Image) ([]byte, error) {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w > thumbnailSize || h > thumbnailSize {
+		if w >= h {
+			w, h = thumbnailSize, max(1, h*thumbnailSize/w)
+		} else {
+			w, h = max(1, w*thumbnailSize/h), thumbnailSize
+		}
+	}
+	thumb := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(thumb, thumb.Bounds(), img, bounds, draw.Over, nil)
+
+	var buf bytes.Buffer
+	err := png.Encode(&buf, thumb)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// imageDataURL embeds a processed image in the page. Only images we decoded
+// and re-encoded ourselves get here, so the data is safe to show inline.
+func imageDataURL(mimeType string, encoded string) template.URL {
+	return template.URL("data:" + mimeType + ";base64," + encoded)
+}
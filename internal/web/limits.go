@@ -0,0 +1,150 @@
+package web
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultMaxBodyBytes          int64 = 2 << 20
+	defaultMaxContentBytes       int64 = 512 << 10
+	defaultAPIKeyMaxBodyBytes    int64 = 16 << 20
+	defaultAPIKeyMaxContentBytes int64 = 8 << 20
+
+	// context keys
+	apiUserKey      string = "apiUser"
+	contentLimitKey string = "contentLimit"
+)
+
+// SizeLimits caps how much a client can send when creating a paste. The
+// body limit covers everything in the request, the content limit just the
+// text of the paste and its files.
+type SizeLimits struct {
+	MaxBodyBytes    int64
+	MaxContentBytes int64
+}
+
+func getByteLimit(env string, fallback int64) (int64, error) {
+	value, found := os.LookupEnv(env)
+	if !found {
+		return fallback, nil
+	}
+	limit, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || limit <= 0 {
+		return 0, fmt.Errorf("%s must be a positive number of bytes", env)
+	}
+	return limit, nil
+}
+
+func getSizeLimits(bodyEnv, contentEnv string, bodyDefault, contentDefault int64) (SizeLimits, error) {
+	body, err := getByteLimit(bodyEnv, bodyDefault)
+	if err != nil {
+		return SizeLimits{}, err
+	}
+	content, err := getByteLimit(contentEnv, contentDefault)
+	if err != nil {
+		return SizeLimits{}, err
+	}
+	return SizeLimits{MaxBodyBytes: body, MaxContentBytes: content}, nil
+}
+
+// getAPIKeys parses API_KEYS, a comma separated list of name:key pairs.
+// The name identifies the client in logs and the audit log.
+func getAPIKeys() (map[string]string, error) {
+	keys := map[string]string{}
+	for _, pair := range strings.Split(os.Getenv("API_KEYS"), ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, key, ok := strings.Cut(pair, ":")
+		if !ok || name == "" || key == "" {
+			return nil, fmt.Errorf("API_KEYS entries must look like name:key")
+		}
+		keys[key] = name
+	}
+	return keys, nil
+}
+
+// identifyClient looks for an API key in the X-API-Key header or as a
+// bearer token, and remembers who it belongs to.
+func (h *WebHandler) identifyClient(c *gin.Context) {
+	key := c.GetHeader("X-API-Key")
+	if key == "" {
+		key, _ = strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	}
+	if key != "" {
+		for k, name := range h.config.APIKeys {
+			if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+				c.Set(apiUserKey, name)
+				break
+			}
+		}
+	}
+	c.Next()
+}
+
+func (h *WebHandler) sizeLimits(c *gin.Context) SizeLimits {
+	if c.GetString(apiUserKey) != "" {
+		return h.config.APIKeyLimits
+	}
+	return h.config.Limits
+}
+
+// limitBody caps the request body before anything reads it, so an
+// oversized paste is never held in memory.
+func (h *WebHandler) limitBody(c *gin.Context) {
+	limits := h.sizeLimits(c)
+	if c.Request.ContentLength > limits.MaxBodyBytes {
+		abortTooLarge(c, limits.MaxBodyBytes)
+		return
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limits.MaxBodyBytes)
+	c.Set(contentLimitKey, limits.MaxContentBytes)
+	c.Next()
+}
+
+func isTooLarge(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr)
+}
+
+func abortTooLarge(c *gin.Context, limit int64) {
+	abortWithError(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("paste is too large, the limit is %s", formatBytes(limit)))
+}
+
+// checkContentSize enforces the content limit set by limitBody.
+func checkContentSize(c *gin.Context, paste PasteEntry) bool {
+	limit := c.GetInt64(contentLimitKey)
+	if limit == 0 {
+		return true
+	}
+	size := int64(len(paste.Content))
+	for _, f := range paste.Files {
+		size += int64(len(f.Content))
+	}
+	if size > limit {
+		abortTooLarge(c, limit)
+		return false
+	}
+	return true
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20 && n%(1<<20) == 0:
+		return fmt.Sprintf("%d MB", n>>20)
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%d KB", n>>10)
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
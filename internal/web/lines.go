@@ -0,0 +1,78 @@
+package web
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// lineRange is an inclusive, 1-based range of lines.
+type lineRange struct {
+	Start int
+	End   int
+}
+
+// parseLineRange parses "12" or "12-40". The L prefix used by the paste
+// page anchors is accepted too, so "L12-L40" can be copied straight over.
+func parseLineRange(s string) (lineRange, error) {
+	s = strings.TrimSpace(s)
+	startStr, endStr, isRange := strings.Cut(s, "-")
+	start, err := strconv.Atoi(strings.TrimPrefix(startStr, "L"))
+	if err != nil || start < 1 {
+		return lineRange{}, fmt.Errorf("invalid line range: %s", s)
+	}
+	end := start
+	if isRange {
+		end, err = strconv.Atoi(strings.TrimPrefix(endStr, "L"))
+		if err != nil || end < start {
+			return lineRange{}, fmt.Errorf("invalid line range: %s", s)
+		}
+	}
+	return lineRange{Start: start, End: end}, nil
+}
+
+// skipLines reads past the first n lines. It returns how many lines it
+// skipped, fewer than n when the content ends first.
+func skipLines(br *bufio.Reader, n int) (int, error) {
+	return copyLines(io.Discard, br, n)
+}
+
+// copyLines copies up to n lines to w without holding more than the reader's
+// buffer in memory. It returns how many lines it copied, fewer than n when
+// the content ends first.
+func copyLines(w io.Writer, br *bufio.Reader, n int) (int, error) {
+	copied := 0
+	// set while in the middle of a line longer than the buffer
+	partial := false
+	for copied < n {
+		line, err := br.ReadSlice('\n')
+		if len(line) > 0 {
+			if _, writeErr := w.Write(line); writeErr != nil {
+				return copied, writeErr
+			}
+		}
+		switch {
+		case err == bufio.ErrBufferFull:
+			partial = true
+			continue
+		case err == io.EOF:
+			if len(line) > 0 || partial {
+				copied++
+			}
+			return copied, nil
+		case err != nil:
+			return copied, err
+		}
+		partial = false
+		copied++
+	}
+	return copied, nil
+}
+
+// atEOF reports whether there is nothing left to read.
+func atEOF(br *bufio.Reader) bool {
+	_, err := br.Peek(1)
+	return err != nil
+}
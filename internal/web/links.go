@@ -0,0 +1,115 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lcrownover/duckpaste/internal/db"
+)
+
+// A short link is a paste holding a single URL that redirects there when
+// opened. Every redirect is counted as a click. Only
+// destinations on allowed domains are accepted so the server can't be used
+// as an open redirect.
+
+// getLinkDomains reads SHORTLINK_ALLOWED_DOMAINS. Without it short links are
+// turned off; "*" allows every domain.
+func getLinkDomains() []string {
+	domains := []string{}
+	for _, d := range strings.Split(os.Getenv("SHORTLINK_ALLOWED_DOMAINS"), ",") {
+		d = strings.ToLower(strings.Trim(strings.TrimSpace(d), "."))
+		if d != "" {
+			domains = append(domains, d)
+		}
+	}
+	return domains
+}
+
+// linkAllowed reports whether the host is one of the domains or a subdomain
+// of one.
+func linkAllowed(host string, domains []string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, d := range domains {
+		if d == "*" || host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseShortLink checks that the content is a single http(s) URL on an
+// allowed domain and returns it.
+func parseShortLink(content string, domains []string) (string, error) {
+	if len(domains) == 0 {
+		return "", fmt.Errorf("short links are not enabled on this server")
+	}
+	link := strings.TrimSpace(content)
+	if link == "" || strings.ContainsAny(link, " \t\r\n") {
+		return "", fmt.Errorf("a short link must be a single URL")
+	}
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return "", fmt.Errorf("a short link must be an http or https URL")
+	}
+	if u.User != nil {
+		return "", fmt.Errorf("a short link can't contain a user name or password")
+	}
+	if !linkAllowed(u.Hostname(), domains) {
+		return "", fmt.Errorf("links to %s are not allowed", u.Hostname())
+	}
+	return u.String(), nil
+}
+
+// followLink answers a read of a short link, with a redirect or with the
+// interstitial page showing where it goes. A destination that is no longer
+// allowed always gets the interstitial.
+func (h *WebHandler) followLink(c *gin.Context, paste PasteEntry) {
+	destination, err := db.DecodeContent(db.ItemContent(paste.Content))
+	if err != nil {
+		abortWithError(c, http.StatusInternalServerError, "failed to decode content of paste")
+		return
+	}
+	_, err = parseShortLink(destination, h.config.LinkDomains)
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Header("Cache-Control", "no-store")
+	if err == nil && !paste.Interstitial {
+		c.Redirect(http.StatusFound, destination)
+		return
+	}
+	c.HTML(http.StatusOK, "templates/link.html", gin.H{
+		"destination": destination,
+		"allowed":     err == nil,
+	})
+}
+
+// getLinkInfo handles GET /:pasteId/link, showing the creator of a short
+// link where it goes and how often it was followed. It needs the edit token
+// and doesn't count as a click.
+func (h *WebHandler) getLinkInfo(c *gin.Context) {
+	pasteID := c.Param("pasteId")
+	item, err := dbClient.ReadItem(db.ItemID(pasteID))
+	if err != nil || isExpired(*item) || !item.ShortLink || !editTokenMatches(*item, requestEditToken(c, pasteID)) {
+		c.HTML(http.StatusNotFound, "templates/notfound.html", nil)
+		return
+	}
+	paste := NewPasteEntryFromDbItem(*item)
+	destination, err := db.DecodeContent(item.Content)
+	if err != nil {
+		abortWithError(c, http.StatusInternalServerError, "failed to decode content of paste")
+		return
+	}
+	c.HTML(http.StatusOK, "templates/link.html", gin.H{
+		"owner":        true,
+		"pasteId":      paste.Id,
+		"pasteURL":     h.pasteURL(paste.Id),
+		"destination":  destination,
+		"clicks":       paste.Views,
+		"maxViews":     paste.MaxViews,
+		"interstitial": paste.Interstitial,
+		"pasteExpires": paste.Expires,
+	})
+}
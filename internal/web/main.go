@@ -9,16 +9,17 @@ import (
 	"net/http"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lcrownover/duckpaste/internal/audit"
 	"github.com/lcrownover/duckpaste/internal/db"
 )
 
 const (
-	defaultLifetime int    = 48
-	containerName   string = "duckpaste"
+	containerName string = "duckpaste"
 )
 
 //go:embed templates
@@ -28,17 +29,64 @@ var templatesFS embed.FS
 var staticFS embed.FS
 
 type PasteEntry struct {
-	Id              string    `json:"id"`
-	ExpirationHours int       `json:"expirationHours" form:"pasteExpirationHours"`
-	Content         string    `json:"content" form:"pasteContent"`
-	Password        string    `json:"password" form:"pastePassword"`
-	DeleteOnRead    bool      `json:"deleteOnRead" form:"pasteDeleteOnRead"`
-	Created         time.Time `json:"created"`
+	Id              string            `json:"id"`
+	ExpirationHours int               `json:"expirationHours" form:"pasteExpirationHours"`
+	Expiration      string            `json:"expiration,omitempty" form:"pasteExpiration"`
+	Content         string            `json:"content" form:"pasteContent"`
+	Password        string            `json:"password" form:"pastePassword"`
+	DeleteOnRead    bool              `json:"deleteOnRead" form:"pasteDeleteOnRead"`
+	AllowedNetworks []string          `json:"allowedNetworks" form:"pasteAllowedNetworks"`
+	Language        string            `json:"language" form:"pasteLanguage"`
+	ContentType     string            `json:"contentType" form:"-"`
+	Format          string            `json:"format" form:"pasteFormat"`
+	MaxViews        int               `json:"maxViews" form:"pasteMaxViews"`
+	Views           int               `json:"views"`
+	Attachments     []AttachmentEntry `json:"attachments" form:"-"`
+	Files           []FileEntry       `json:"files" form:"-"`
+	Revisions       []RevisionEntry   `json:"revisions" form:"-"`
+	Created         time.Time         `json:"created"`
+	Updated         time.Time         `json:"updated"`
+	ForkOf          string            `json:"forkOf" form:"pasteForkOf"`
+	Title           string            `json:"title" form:"pasteTitle"`
+	Description     string            `json:"description" form:"pasteDescription"`
+	Tags            []string          `json:"tags" form:"pasteTags"`
+	ShortLink       bool              `json:"shortLink" form:"pasteShortLink"`
+	Interstitial    bool              `json:"interstitial" form:"pasteInterstitial"`
+	// When the paste expires, nil if it never does
+	Expires *time.Time `json:"expires" form:"-"`
+	// Only set in the response to creating the paste
+	EditToken string `json:"editToken,omitempty" form:"-"`
+	// Set when this read used up the paste
+	Burned bool `json:"burned"`
+
+	// attachment data and old content, only used server side
+	attachments []db.Attachment
+	revisions   []db.ItemRevision
+	// API client that creates the paste
+	owner string
 }
 
 type WebConfig struct {
 	Host string
 	Port string
+	// Proxies whose X-Forwarded-For header we believe
+	TrustedProxies []string
+	// Who may create and who may read pastes
+	CreateACL *NetworkACL
+	ReadACL   *NetworkACL
+	// Default chroma style for highlighted pastes
+	Theme string
+	// How much anonymous and API key clients can send
+	Limits       SizeLimits
+	APIKeyLimits SizeLimits
+	// API key to client name
+	APIKeys map[string]string
+	// How long pastes may live
+	Expiration ExpirationLimits
+	// Domains short links may point to
+	LinkDomains []string
+	// Serve the hastebin routes
+	Hastebin bool
 }
 
 func (wc *WebConfig) Address() string {
@@ -64,11 +112,54 @@ func NewWebHandler(c *WebConfig, server *gin.Engine) *WebHandler {
 	h := &WebHandler{config: c, server: server}
 	pattern := "templates/*html"
 	LoadHTMLFromEmbedFS(server, templatesFS, pattern)
-	server.GET("/api/paste", h.getPasteApi)
-	server.POST("/api/paste", h.createPasteApi)
-	server.GET("/", h.getRoot)
-	server.GET("/:pasteId", h.getPaste)
+
+	err := server.SetTrustedProxies(c.TrustedProxies)
+	if err != nil {
+		slog.Error("failed to set trusted proxies: "+err.Error(), "source", "NewWebHandler")
+	}
+	server.Use(h.identifyClient)
+	canCreate := requireNetwork(c.CreateACL)
+	canRead := requireNetwork(c.ReadACL)
+
+	server.GET("/api/paste", canRead, h.getPasteApi)
+	server.GET("/api/pastes", canRead, h.listPastesApi)
+	server.POST("/api/paste", canCreate, h.limitBody, h.createPasteApi)
+	server.GET("/", canCreate, h.getRoot)
+	server.POST("/", plainText, canCreate, h.limitBody, h.createPlainPaste)
+	server.GET("/:pasteId", htmlPage, canRead, h.getPaste)
+	server.POST("/:pasteId", htmlPage, canRead, h.getPaste)
+	server.GET("/raw/:pasteId", canRead, h.getRaw)
+	server.GET("/download/:pasteId", canRead, h.getDownload)
+	server.GET("/attachment/:pasteId/:index", canRead, h.getAttachment)
+	server.GET("/zip/:pasteId", canRead, h.getZip)
+	server.GET("/:pasteId/qr", canRead, h.getQR)
+	server.GET("/:pasteId/link", htmlPage, canRead, h.getLinkInfo)
+	server.GET("/:pasteId/rev/:rev", htmlPage, canRead, h.getRevision)
+	server.POST("/:pasteId/rev/:rev", htmlPage, canRead, h.getRevision)
+	server.POST("/api/paste/:pasteId/fork", canRead, canCreate, h.forkPasteApi)
+	server.GET("/diff/:a/:b", htmlPage, canRead, h.getDiff)
+	server.POST("/diff/:a/:b", htmlPage, canRead, h.getDiff)
+	server.GET("/api/diff/:a/:b", canRead, h.getDiffApi)
+	server.POST("/:pasteId/edit", canCreate, h.limitBody, h.editPaste)
+	server.PUT("/api/paste/:pasteId", canCreate, h.limitBody, h.updatePasteApi)
 	server.GET("/about", h.getAbout)
+	server.GET("/themes/:theme", h.getThemeCSS)
+
+	v1 := server.Group("/api/v1", jsonAPI)
+	v1.POST("/pastes", canCreate, h.limitBody, h.createPasteApi)
+	v1.GET("/pastes", canRead, h.listPastesApi)
+	v1.GET("/pastes/:pasteId", canRead, h.getPasteV1)
+	v1.PUT("/pastes/:pasteId", canCreate, h.limitBody, h.updatePasteApi)
+	v1.DELETE("/pastes/:pasteId", canCreate, h.deletePasteApi)
+	v1.POST("/pastes/:pasteId/fork", canRead, canCreate, h.forkPasteApi)
+	v1.GET("/diff/:a/:b", canRead, h.getDiffApi)
+	server.GET("/api/openapi.json", h.getOpenAPI)
+	server.GET("/api/docs", h.getAPIDocs)
+	if c.Hastebin {
+		// /raw/:pasteId already answers like hastebin's raw route
+		server.POST("/documents", jsonAPI, canCreate, h.limitBody, h.createDocument)
+		server.GET("/documents/:pasteId", jsonAPI, canRead, h.getDocument)
+	}
 
 	// drill down into static FS
 	staticFS, err := fs.Sub(staticFS, "static")
@@ -82,7 +173,7 @@ func NewWebHandler(c *WebConfig, server *gin.Engine) *WebHandler {
 	return h
 }
 
-func GetWebConfig() *WebConfig {
+func GetWebConfig() (*WebConfig, error) {
 	host, found := os.LookupEnv("SERVER_HOST")
 	if !found {
 		host = "localhost"
@@ -91,10 +182,53 @@ func GetWebConfig() *WebConfig {
 	if !found {
 		port = "8080"
 	}
-	return &WebConfig{
-		Host: host,
-		Port: port,
+	theme, found := os.LookupEnv("HIGHLIGHT_THEME")
+	if !found {
+		theme = defaultTheme
+	}
+	trustedProxies := splitNetworks(os.Getenv("SERVER_TRUSTED_PROXIES"))
+	createACL, err := getNetworkACL("CREATE_ALLOW_NETWORKS", "CREATE_DENY_NETWORKS")
+	if err != nil {
+		return nil, err
+	}
+	readACL, err := getNetworkACL("READ_ALLOW_NETWORKS", "READ_DENY_NETWORKS")
+	if err != nil {
+		return nil, err
+	}
+	limits, err := getSizeLimits("MAX_BODY_BYTES", "MAX_CONTENT_BYTES", defaultMaxBodyBytes, defaultMaxContentBytes)
+	if err != nil {
+		return nil, err
+	}
+	apiKeyLimits, err := getSizeLimits("API_KEY_MAX_BODY_BYTES", "API_KEY_MAX_CONTENT_BYTES", defaultAPIKeyMaxBodyBytes, defaultAPIKeyMaxContentBytes)
+	if err != nil {
+		return nil, err
+	}
+	apiKeys, err := getAPIKeys()
+	if err != nil {
+		return nil, err
+	}
+	expiration, err := getExpirationLimits()
+	if err != nil {
+		return nil, err
+	}
+	hastebin, err := getHastebinCompat()
+	if err != nil {
+		return nil, err
 	}
+	return &WebConfig{
+		Host:           host,
+		Port:           port,
+		TrustedProxies: trustedProxies,
+		CreateACL:      createACL,
+		ReadACL:        readACL,
+		Theme:          validTheme(theme, defaultTheme),
+		Limits:         limits,
+		APIKeyLimits:   apiKeyLimits,
+		APIKeys:        apiKeys,
+		Expiration:     expiration,
+		LinkDomains:    getLinkDomains(),
+		Hastebin:       hastebin,
+	}, nil
 }
 
 func StartServer() {
@@ -112,7 +246,11 @@ func StartServer() {
 	}
 
 	// get listen config from env
-	wc := GetWebConfig()
+	wc, err := GetWebConfig()
+	if err != nil {
+		slog.Error("failed to get web config: "+err.Error(), "source", "StartServer")
+		return
+	}
 
 	gin.SetMode(gin.ReleaseMode)
 	server := gin.Default()
@@ -160,31 +298,129 @@ func LoadAndAddToRoot(funcMap template.FuncMap, rootTemplate *template.Template,
 func (h *WebHandler) createPasteApi(c *gin.Context) {
 	var paste PasteEntry
 
-	err := c.Bind(&paste)
+	err := c.ShouldBind(&paste)
+	if isTooLarge(err) {
+		abortTooLarge(c, h.sizeLimits(c).MaxBodyBytes)
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusBadRequest, errorResponse{
-			fmt.Sprintf("couldn't unmarshal payload to PasteEntry struct: %s", err),
-		})
+		abortWithError(c, http.StatusBadRequest, fmt.Sprintf("couldn't unmarshal payload to PasteEntry struct: %s", err))
 		return
 	}
 
-	if strings.TrimSpace(paste.Content) == "" {
-		c.JSON(http.StatusBadRequest, errorResponse{
-			"please provide actual content",
-		})
+	attachments, err := readAttachments(c)
+	if isTooLarge(err) {
+		abortTooLarge(c, h.sizeLimits(c).MaxBodyBytes)
+		return
+	}
+	if err != nil {
+		abortWithError(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	paste, err = createPasteEntry(paste)
+	if c.ContentType() != gin.MIMEJSON {
+		paste.Files = readFormFiles(c)
+	}
+	paste.Files, err = normalizeFiles(paste.Files)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, errorResponse{
-			fmt.Sprintf("failed to create paste entry: %s", err),
-		})
+		abortWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !checkContentSize(c, paste) {
+		return
+	}
+
+	if strings.TrimSpace(paste.Content) == "" && len(attachments) == 0 && len(paste.Files) == 0 {
+		abortWithError(c, http.StatusBadRequest, "please provide actual content")
+		return
+	}
+
+	if paste.ShortLink {
+		if len(attachments) > 0 || len(paste.Files) > 0 {
+			abortWithError(c, http.StatusBadRequest, "a short link can't have files")
+			return
+		}
+		paste.Content, err = parseShortLink(paste.Content, h.config.LinkDomains)
+		if err != nil {
+			abortWithError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		paste.Format = ""
+	}
+	paste.Interstitial = paste.Interstitial && paste.ShortLink
+
+	paste.Language, err = normalizeLanguage(paste.Language)
+	if err != nil {
+		abortWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if paste.MaxViews < 0 {
+		abortWithError(c, http.StatusBadRequest, "maxViews can't be negative")
 		return
 	}
 
+	paste.Format, err = normalizeFormat(paste.Format)
+	if err != nil {
+		abortWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = validateForkOf(paste.ForkOf)
+	if err != nil {
+		abortWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	paste.Expires, err = resolveExpiry(paste.Expiration, paste.ExpirationHours, db.GetCurrentTime(), h.config.Expiration)
+	if err != nil {
+		abortWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	paste.AllowedNetworks, err = normalizeNetworks(paste.AllowedNetworks)
+	if err != nil {
+		abortWithError(c, http.StatusBadRequest, fmt.Sprintf("invalid allowed networks: %s", err))
+		return
+	}
+
+	metadata, err := normalizeMetadata(pasteMetadata{paste.Title, paste.Description, paste.Tags})
+	if err != nil {
+		abortWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	paste.Title, paste.Description, paste.Tags = metadata.Title, metadata.Description, metadata.Tags
+	paste.owner = c.GetString(apiUserKey)
+
+	paste, err = createPasteEntry(paste, attachments)
+	if err != nil {
+		recordEvent(c, audit.ActionCreate, paste.Id, audit.OutcomeFailure, err.Error())
+		abortWithError(c, http.StatusInternalServerError, fmt.Sprintf("failed to create paste entry: %s", err))
+		return
+	}
+	recordEvent(c, audit.ActionCreate, paste.Id, audit.OutcomeSuccess, "")
+	giveEditToken(c, paste)
+
 	pasteUrl := fmt.Sprintf("/%s", paste.Id)
-	c.Redirect(http.StatusFound, pasteUrl)
+	if paste.ShortLink {
+		// opening the link itself would follow it
+		pasteUrl += "/link"
+	}
+	if wantsPage(c) {
+		c.Redirect(http.StatusFound, pasteUrl)
+		return
+	}
+	c.Header("Location", pasteUrl)
+	c.JSON(http.StatusCreated, createResponse{
+		Id:          paste.Id,
+		URL:         h.pasteURL(paste.Id),
+		RawURL:      h.rawURL(paste.Id),
+		ContentType: paste.ContentType,
+		Language:    paste.Language,
+		ExpiresAt:   paste.Expires,
+		DeleteToken: paste.EditToken,
+	})
 }
 
 func (h *WebHandler) getPasteApi(c *gin.Context) {
@@ -196,64 +432,193 @@ func (h *WebHandler) getPasteApi(c *gin.Context) {
 		return
 	}
 
-	paste, err := getPasteEntry(pasteId)
+	h.respondPaste(c, pasteId)
+}
+
+func (h *WebHandler) getRoot(c *gin.Context) {
+	h.renderForm(c, forkResponse{})
+}
+
+// renderForm shows the paste form, prefilled when forking.
+func (h *WebHandler) renderForm(c *gin.Context, prefill forkResponse) {
+	maxLifetime := ""
+	if h.config.Expiration.Max > 0 {
+		maxLifetime = formatLifetime(h.config.Expiration.Max)
+	}
+	c.HTML(http.StatusOK, "templates/index.html", gin.H{
+		"languages":       formLanguages,
+		"maxContent":      formatBytes(h.config.Limits.MaxContentBytes),
+		"defaultLifetime": formatLifetime(h.config.Expiration.Default),
+		"maxLifetime":     maxLifetime,
+		"allowNever":      h.config.Expiration.AllowNever,
+		"linksEnabled":    len(h.config.LinkDomains) > 0,
+		"prefill":         prefill,
+	})
+}
+
+func (h *WebHandler) getPaste(c *gin.Context) {
+	pasteID := c.Param("pasteId")
+	paste, err := checkPaste(c, pasteID, requestPassword(c))
 	if err != nil {
-		c.JSON(http.StatusNotFound, errorResponse{
-			fmt.Sprintf("no paste found with id: %s", pasteId),
-		})
+		h.pasteReadError(c, err)
 		return
 	}
+	if !paste.ShortLink && !pageInRange(c, paste) {
+		return
+	}
+	paste, err = consumePaste(c, paste)
+	if err != nil {
+		h.pasteReadError(c, err)
+		return
+	}
+	if paste.ShortLink {
+		h.followLink(c, paste)
+		return
+	}
+	h.renderPaste(c, paste, len(paste.Revisions))
+}
 
-	// If the paste is set to delete on read and it's been longer than 10sec since it was created, delete it
-	if paste.DeleteOnRead && paste.Created.Add(time.Second*10).Before(time.Now()) {
-		err = deletePasteEntry(paste)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, errorResponse{
-				fmt.Sprintf("failed to delete paste: %s", err),
-			})
-			return
-		}
+// requestedPage is the page of a large paste the request asks for, or 0
+// when the paste is shown whole: when it's small, binary or burned, since a
+// burned paste can't be fetched again, or when the reader asks for all of
+// it.
+func requestedPage(c *gin.Context, paste PasteEntry) int {
+	if decodedSize(paste.Content) <= maxInlineBytes || c.Query("full") != "" || paste.Burned || paste.ContentType == contentTypeBinary {
+		return 0
 	}
+	number, _ := strconv.Atoi(c.Query("page"))
+	return max(1, number)
+}
 
-	c.JSON(http.StatusOK, paste)
+// pageInRange answers with a 404 when the request asks for a page past the
+// end of the paste. It's checked before the read is counted, so a bad page
+// doesn't use up a view.
+func pageInRange(c *gin.Context, paste PasteEntry) bool {
+	number := requestedPage(c, paste)
+	if number <= 1 {
+		return true
+	}
+	_, p, err := readPage(paste.Content, number)
+	if err == nil && p.Number > p.Pages {
+		c.HTML(http.StatusNotFound, "templates/notfound.html", nil)
+		return false
+	}
+	return true
+}
 
+// passwordField is an input on the password page.
+type passwordField struct {
+	Name  string
+	Label string
 }
 
-func (h *WebHandler) getRoot(c *gin.Context) {
-	c.HTML(http.StatusOK, "templates/index.html", nil)
+// pasteReadError answers a page request for a paste that couldn't be opened.
+func (h *WebHandler) pasteReadError(c *gin.Context, err error) {
+	switch readStatus(err) {
+	case http.StatusUnauthorized:
+		c.HTML(http.StatusUnauthorized, "templates/password.html", gin.H{
+			"action": c.Request.URL.RequestURI(),
+			"retry":  c.PostForm("pastePassword") != "",
+			"fields": []passwordField{{"pastePassword", "password"}},
+		})
+	case http.StatusNotFound, http.StatusForbidden:
+		c.HTML(readStatus(err), "templates/notfound.html", nil)
+	default:
+		abortWithError(c, readStatus(err), err.Error())
+	}
 }
 
-func (h *WebHandler) getPaste(c *gin.Context) {
-	pasteID := c.Param("pasteId")
-	paste, err := getPasteEntry(pasteID)
+// renderPaste shows the paste page for the given revision of the content.
+func (h *WebHandler) renderPaste(c *gin.Context, paste PasteEntry, revision int) {
+	// large pastes are shown a page at a time
+	var page *contentPage
+	var decodedContent string
+	var err error
+	if number := requestedPage(c, paste); number > 0 {
+		var p contentPage
+		decodedContent, p, err = readPage(paste.Content, number)
+		if err == nil && p.Number > p.Pages {
+			c.HTML(http.StatusNotFound, "templates/notfound.html", nil)
+			return
+		}
+		p.pageLinks(c.Request.URL)
+		page = &p
+	} else {
+		decodedContent, err = db.DecodeContent(db.ItemContent(paste.Content))
+	}
 	if err != nil {
-		c.HTML(http.StatusNotFound, "templates/notfound.html", nil)
+		abortWithError(c, http.StatusNotFound, "failed to decode content of paste")
 		return
 	}
-	// If the paste is set to delete on read and it's been longer than 10sec since it was created, delete it
-	if paste.DeleteOnRead && paste.Created.Add(time.Second*10).Before(time.Now()) {
-		err = deletePasteEntry(paste)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, errorResponse{
-				fmt.Sprintf("failed to delete paste: %s", err),
-			})
-			return
-		}
+	// pastes from before content types and languages were stored get
+	// detected on the fly
+	contentType := paste.ContentType
+	if contentType == "" && decodedContent != "" {
+		contentType = detectContentType(decodedContent)
 	}
-	decodedContent, err := db.DecodeContent(db.ItemContent(paste.Content))
+	language := paste.Language
+	if language == "" {
+		language = defaultLanguageFor(contentType, decodedContent)
+	}
+	// binary content is offered as a download instead of shown
+	binarySize := ""
+	if contentType == contentTypeBinary {
+		binarySize = formatBytes(decodedSize(paste.Content))
+		decodedContent = ""
+	}
+	theme := validTheme(c.Query("theme"), h.config.Theme)
+	firstLine := 1
+	if page != nil {
+		firstLine = page.FirstLine
+	}
+	highlighted, err := highlight(decodedContent, language, "L", firstLine)
 	if err != nil {
-		c.JSON(http.StatusNotFound, errorResponse{
-			"failed to decode content of paste",
-		})
+		slog.Error("failed to highlight paste: "+err.Error(), "id", paste.Id, "source", "renderPaste")
+		highlighted = template.HTML("<pre><code>" + template.HTMLEscapeString(decodedContent) + "</code></pre>")
 	}
-	// TODO(lcrown): fix https or http
-	pasteURL := fmt.Sprintf("http://%s/%s", h.config.Address(), paste.Id)
+	var rendered template.HTML
+	if paste.Format == formatMarkdown && decodedContent != "" && page == nil {
+		rendered, err = renderMarkdown(decodedContent)
+		if err != nil {
+			slog.Error("failed to render markdown: "+err.Error(), "id", paste.Id, "source", "renderPaste")
+		}
+	}
+	giveVisitToken(c, paste)
+	// only the browser that created the paste has the edit cookie
+	editable := !paste.Burned && page == nil && requestEditToken(c, paste.Id) != ""
 	c.HTML(http.StatusOK, "templates/paste.html", gin.H{
-		"pasteURL":     pasteURL,
-		"pasteContent": decodedContent,
+		"pasteId":          paste.Id,
+		"pasteURL":         h.pasteURL(paste.Id),
+		"pasteTitle":       paste.Title,
+		"pasteDescription": paste.Description,
+		"pasteTags":        paste.Tags,
+		"pasteExpires":     paste.Expires,
+		"pasteContent":     decodedContent,
+		"pasteHighlighted": highlighted,
+		"pasteLanguage":    language,
+		"pasteContentType": contentType,
+		"pasteBinary":      binarySize,
+		"page":             page,
+		"pasteRendered":    rendered,
+		"attachments":      attachmentLinks(paste),
+		"files":            fileBlocks(paste),
+		"forkOf":           forkedFrom(paste),
+		"revisions":        paste.Revisions,
+		"revision":         revision,
+		"latest":           revision == len(paste.Revisions),
+		"editable":         editable && revision == len(paste.Revisions),
+		"shareable":        !paste.Burned,
+		"theme":            theme,
+		"themes":           themeNames(),
 	})
 }
 
+// pasteURL is the address people share for a paste.
+func (h *WebHandler) pasteURL(pasteID string) string {
+	// TODO(lcrown): fix https or http
+	return fmt.Sprintf("http://%s/%s", h.config.Address(), pasteID)
+}
+
 func (h *WebHandler) getAbout(c *gin.Context) {
 	c.HTML(http.StatusOK, "templates/about.html", nil)
 }
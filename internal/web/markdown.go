@@ -0,0 +1,67 @@
+package web
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"regexp"
+
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+const (
+	formatCode     string = "code"
+	formatMarkdown string = "markdown"
+)
+
+// normalizeFormat validates how the paste should be displayed, defaulting
+// to highlighted code.
+func normalizeFormat(format string) (string, error) {
+	switch format {
+	case "", formatCode:
+		return formatCode, nil
+	case formatMarkdown:
+		return formatMarkdown, nil
+	}
+	return "", fmt.Errorf("unknown format: %s", format)
+}
+
+// markdownPolicy is what's left of the rendered markdown after sanitizing.
+// Raw HTML in the paste is passed through by goldmark and cleaned up here.
+var markdownPolicy = func() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	// GFM task lists
+	p.AllowElements("input")
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+	// token classes from the code highlighter
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^[a-z0-9]+( [a-z0-9]+)*$`)).OnElements("span", "pre", "code")
+	return p
+}()
+
+// renderMarkdown converts GFM to sanitized HTML, highlighting fenced code
+// blocks like pastes are.
+func renderMarkdown(content string) (template.HTML, error) {
+	md := goldmark.New(
+		goldmark.WithExtensions(
+			extension.GFM,
+			highlighting.NewHighlighting(
+				highlighting.WithFormatOptions(chromahtml.WithClasses(true), chromahtml.TabWidth(4)),
+			),
+		),
+		goldmark.WithRendererOptions(html.WithUnsafe()),
+	)
+
+	var buf bytes.Buffer
+	err := md.Convert([]byte(content), &buf)
+	if err != nil {
+		return "", fmt.Errorf("failed to render markdown: %v", err)
+	}
+
+	return template.HTML(markdownPolicy.SanitizeBytes(buf.Bytes())), nil
+}
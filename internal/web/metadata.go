@@ -0,0 +1,122 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lcrownover/duckpaste/internal/db"
+)
+
+// Titles, descriptions and tags are optional and only there to help people
+// find their pastes again.
+
+const (
+	maxTitleLength       = 200
+	maxDescriptionLength = 2000
+	maxTags              = 10
+	maxTagLength         = 32
+)
+
+var tagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// pasteMetadata are the descriptive fields of a paste.
+type pasteMetadata struct {
+	Title       string
+	Description string
+	Tags        []string
+}
+
+// normalizeMetadata trims the title and description and lowercases the tags.
+// Tags may come in one comma or space separated string, like from the form.
+func normalizeMetadata(m pasteMetadata) (pasteMetadata, error) {
+	m.Title = strings.Join(strings.Fields(m.Title), " ")
+	if utf8.RuneCountInString(m.Title) > maxTitleLength {
+		return m, fmt.Errorf("title can't be longer than %d characters", maxTitleLength)
+	}
+	m.Description = strings.TrimSpace(m.Description)
+	if utf8.RuneCountInString(m.Description) > maxDescriptionLength {
+		return m, fmt.Errorf("description can't be longer than %d characters", maxDescriptionLength)
+	}
+
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, t := range m.Tags {
+		for _, tag := range strings.FieldsFunc(t, isTagSeparator) {
+			tag = strings.ToLower(strings.TrimPrefix(tag, "#"))
+			if len(tag) > maxTagLength || !tagPattern.MatchString(tag) {
+				return m, fmt.Errorf("invalid tag: %s", tag)
+			}
+			if seen[tag] {
+				continue
+			}
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) > maxTags {
+		return m, fmt.Errorf("a paste can have at most %d tags", maxTags)
+	}
+	m.Tags = tags
+	return m, nil
+}
+
+func isTagSeparator(r rune) bool {
+	return r == ',' || unicode.IsSpace(r)
+}
+
+// PasteSummary is a paste in a listing, without its content.
+type PasteSummary struct {
+	Id          string     `json:"id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Tags        []string   `json:"tags"`
+	Language    string     `json:"language"`
+	Created     time.Time  `json:"created"`
+	Expires     *time.Time `json:"expires"`
+	URL         string     `json:"url"`
+}
+
+func NewPasteSummary(item db.Item) PasteSummary {
+	return PasteSummary{
+		Id:          string(item.Id),
+		Title:       item.Title,
+		Description: item.Description,
+		Tags:        item.Tags,
+		Language:    item.Language,
+		Created:     item.Created,
+		Expires:     itemExpiry(item),
+		URL:         fmt.Sprintf("/%s", item.Id),
+	}
+}
+
+// listPastesApi handles GET /api/pastes, listing the pastes made with the
+// client's API key, optionally only those with ?tag=.
+func (h *WebHandler) listPastesApi(c *gin.Context) {
+	owner := c.GetString(apiUserKey)
+	if owner == "" {
+		c.JSON(http.StatusUnauthorized, errorResponse{
+			"listing pastes requires an API key",
+		})
+		return
+	}
+	tag := strings.ToLower(strings.TrimPrefix(c.Query("tag"), "#"))
+
+	items, err := dbClient.ListItems(owner, tag)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errorResponse{
+			fmt.Sprintf("failed to list pastes: %s", err),
+		})
+		return
+	}
+	pastes := []PasteSummary{}
+	for _, item := range items {
+		pastes = append(pastes, NewPasteSummary(item))
+	}
+	c.JSON(http.StatusOK, pastes)
+}
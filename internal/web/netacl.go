@@ -0,0 +1,137 @@
+package web
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lcrownover/duckpaste/internal/audit"
+)
+
+// NetworkACL decides which client addresses may use a group of routes.
+// Deny always wins. An empty allow list allows everything not denied.
+type NetworkACL struct {
+	Allow []*net.IPNet
+	Deny  []*net.IPNet
+}
+
+func (a *NetworkACL) Allowed(ip net.IP) bool {
+	if a == nil {
+		return true
+	}
+	if ip == nil {
+		return false
+	}
+	if containsIP(a.Deny, ip) {
+		return false
+	}
+	if len(a.Allow) == 0 {
+		return true
+	}
+	return containsIP(a.Allow, ip)
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, n := range networks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitNetworks splits a comma or whitespace separated list of networks.
+func splitNetworks(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	})
+}
+
+// ParseNetworks parses CIDRs, accepting bare addresses as single hosts.
+func ParseNetworks(networks []string) ([]*net.IPNet, error) {
+	parsed := []*net.IPNet{}
+	for _, n := range networks {
+		if !strings.Contains(n, "/") {
+			ip := net.ParseIP(n)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid network: %s", n)
+			}
+			// built directly, since appending /32 to an IPv4-mapped
+			// address like ::ffff:10.0.0.1 would make it ::/32
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			parsed = append(parsed, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(n)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network: %s", n)
+		}
+		parsed = append(parsed, ipNet)
+	}
+	return parsed, nil
+}
+
+// normalizeNetworks flattens networks submitted either as a JSON list or
+// as a single comma separated form field, and validates them.
+func normalizeNetworks(networks []string) ([]string, error) {
+	normalized := []string{}
+	for _, n := range networks {
+		normalized = append(normalized, splitNetworks(n)...)
+	}
+	_, err := ParseNetworks(normalized)
+	if err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+func getNetworkACL(allowEnv, denyEnv string) (*NetworkACL, error) {
+	allow, err := ParseNetworks(splitNetworks(os.Getenv(allowEnv)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", allowEnv, err)
+	}
+	deny, err := ParseNetworks(splitNetworks(os.Getenv(denyEnv)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", denyEnv, err)
+	}
+	return &NetworkACL{Allow: allow, Deny: deny}, nil
+}
+
+// requireNetwork rejects requests from clients the ACL doesn't allow.
+// The client address comes from gin, which only honors X-Forwarded-For
+// from the configured trusted proxies.
+func requireNetwork(acl *NetworkACL) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !acl.Allowed(net.ParseIP(c.ClientIP())) {
+			slog.Info("request denied by network acl", "clientIP", c.ClientIP(), "path", c.Request.URL.Path, "source", "requireNetwork")
+			abortWithError(c, http.StatusForbidden, "access denied from your network")
+			return
+		}
+		c.Next()
+	}
+}
+
+// pasteAllowsClient checks the paste's own network restriction, if any.
+func pasteAllowsClient(c *gin.Context, paste PasteEntry) bool {
+	if len(paste.AllowedNetworks) == 0 {
+		return true
+	}
+	allow, err := ParseNetworks(paste.AllowedNetworks)
+	if err != nil {
+		slog.Error("failed to parse paste networks: "+err.Error(), "id", paste.Id, "source", "pasteAllowsClient")
+		return false
+	}
+	acl := &NetworkACL{Allow: allow}
+	if !acl.Allowed(net.ParseIP(c.ClientIP())) {
+		recordEvent(c, audit.ActionRead, paste.Id, audit.OutcomeDenied, "client not in paste networks")
+		return false
+	}
+	return true
+}
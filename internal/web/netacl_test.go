@@ -0,0 +1,113 @@
+package web
+
+import (
+	"net"
+	"testing"
+)
+
+func TestParseNetworks(t *testing.T) {
+	tests := []struct {
+		network string
+		want    string // empty when the network is refused
+	}{
+		{"10.0.0.0/8", "10.0.0.0/8"},
+		{"10.1.2.3/8", "10.0.0.0/8"},
+		{"192.168.1.7", "192.168.1.7/32"},
+		{"0.0.0.0/0", "0.0.0.0/0"},
+		{"2001:db8::/32", "2001:db8::/32"},
+		{"2001:db8::1", "2001:db8::1/128"},
+		{"::1", "::1/128"},
+		{"::ffff:10.0.0.1", "10.0.0.1/32"},
+
+		{"", ""},
+		{"10.0.0", ""},
+		{"10.0.0.0/33", ""},
+		{"2001:db8::/129", ""},
+		{"256.0.0.1", ""},
+		{"example.com", ""},
+		{"10.0.0.0/", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.network, func(t *testing.T) {
+			got, err := ParseNetworks([]string{tt.network})
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("ParseNetworks(%q) = %v, want an error", tt.network, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseNetworks(%q) failed: %v", tt.network, err)
+			}
+			if len(got) != 1 || got[0].String() != tt.want {
+				t.Errorf("ParseNetworks(%q) = %v, want %s", tt.network, got, tt.want)
+			}
+		})
+	}
+
+	// one bad network fails the whole list
+	_, err := ParseNetworks([]string{"10.0.0.0/8", "nope"})
+	if err == nil {
+		t.Error("a list with an invalid network was accepted")
+	}
+}
+
+func TestNormalizeNetworks(t *testing.T) {
+	got, err := normalizeNetworks([]string{"10.0.0.0/8, 192.168.1.1", "2001:db8::/32\n::1\t172.16.0.0/12", ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"10.0.0.0/8", "192.168.1.1", "2001:db8::/32", "::1", "172.16.0.0/12"}
+	if len(got) != len(want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+	}
+
+	_, err = normalizeNetworks([]string{"10.0.0.0/8,nope"})
+	if err == nil {
+		t.Error("an invalid network was accepted")
+	}
+}
+
+func TestNetworkACL(t *testing.T) {
+	networks := func(s ...string) []*net.IPNet {
+		parsed, err := ParseNetworks(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+	tests := []struct {
+		name string
+		acl  *NetworkACL
+		ip   string
+		want bool
+	}{
+		{"no acl", nil, "203.0.113.1", true},
+		{"empty acl", &NetworkACL{}, "203.0.113.1", true},
+		{"no address", &NetworkACL{}, "", false},
+		{"in allow", &NetworkACL{Allow: networks("10.0.0.0/8")}, "10.2.3.4", true},
+		{"outside allow", &NetworkACL{Allow: networks("10.0.0.0/8")}, "11.0.0.1", false},
+		{"single host", &NetworkACL{Allow: networks("192.168.1.7")}, "192.168.1.7", true},
+		{"next to single host", &NetworkACL{Allow: networks("192.168.1.7")}, "192.168.1.8", false},
+		{"in deny", &NetworkACL{Deny: networks("10.0.0.0/8")}, "10.2.3.4", false},
+		{"outside deny", &NetworkACL{Deny: networks("10.0.0.0/8")}, "11.0.0.1", true},
+		{"deny wins", &NetworkACL{Allow: networks("10.0.0.0/8"), Deny: networks("10.1.0.0/16")}, "10.1.2.3", false},
+		{"allowed around deny", &NetworkACL{Allow: networks("10.0.0.0/8"), Deny: networks("10.1.0.0/16")}, "10.2.2.3", true},
+		{"ipv6 in allow", &NetworkACL{Allow: networks("2001:db8::/32")}, "2001:db8::42", true},
+		{"ipv6 outside allow", &NetworkACL{Allow: networks("2001:db8::/32")}, "2001:db9::1", false},
+		{"ipv4 mapped address", &NetworkACL{Allow: networks("10.0.0.0/8")}, "::ffff:10.0.0.1", true},
+		{"ipv4 allow and ipv6 client", &NetworkACL{Allow: networks("10.0.0.0/8")}, "::1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.acl.Allowed(net.ParseIP(tt.ip)); got != tt.want {
+				t.Errorf("Allowed(%q) = %v, want %v", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,312 @@
+package web
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The API is described by the OpenAPI document in openapi.json. It's served
+// as is at /api/openapi.json and rendered as a page at /api/docs, so the
+// docs work without loading anything from elsewhere. openapi_test.go checks
+// it against the routes and response types.
+
+//go:embed openapi.json
+var openAPIDocument []byte
+
+// openAPISpec is the part of an OpenAPI document the docs page shows.
+type openAPISpec struct {
+	Info struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		Version     string `json:"version"`
+	} `json:"info"`
+	Tags []struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	} `json:"tags"`
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Parameters    map[string]openAPIParameter   `json:"parameters"`
+		RequestBodies map[string]openAPIRequestBody `json:"requestBodies"`
+		Responses     map[string]openAPIResponse    `json:"responses"`
+		Schemas       map[string]openAPISchema      `json:"schemas"`
+	} `json:"components"`
+}
+
+type openAPIOperation struct {
+	Tags        []string                   `json:"tags"`
+	Summary     string                     `json:"summary"`
+	Description string                     `json:"description"`
+	Parameters  []openAPIParameter         `json:"parameters"`
+	RequestBody *openAPIRequestBody        `json:"requestBody"`
+	Responses   map[string]openAPIResponse `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Ref         string `json:"$ref"`
+	Name        string `json:"name"`
+	In          string `json:"in"`
+	Required    bool   `json:"required"`
+	Description string `json:"description"`
+}
+
+type openAPIRequestBody struct {
+	Ref     string                  `json:"$ref"`
+	Content map[string]openAPIMedia `json:"content"`
+}
+
+type openAPIResponse struct {
+	Ref         string                  `json:"$ref"`
+	Description string                  `json:"description"`
+	Content     map[string]openAPIMedia `json:"content"`
+}
+
+type openAPIMedia struct {
+	Schema openAPISchema `json:"schema"`
+}
+
+type openAPISchema struct {
+	Ref         string                   `json:"$ref"`
+	Type        string                   `json:"type"`
+	Format      string                   `json:"format"`
+	Description string                   `json:"description"`
+	Nullable    bool                     `json:"nullable"`
+	Enum        []string                 `json:"enum"`
+	Items       *openAPISchema           `json:"items"`
+	Properties  map[string]openAPISchema `json:"properties"`
+}
+
+func loadOpenAPISpec() (openAPISpec, error) {
+	var spec openAPISpec
+	err := json.Unmarshal(openAPIDocument, &spec)
+	if err != nil {
+		return spec, fmt.Errorf("failed to parse openapi.json: %v", err)
+	}
+	return spec, nil
+}
+
+// refName is the last part of a local reference like
+// #/components/schemas/Paste.
+func refName(ref string) string {
+	return ref[strings.LastIndex(ref, "/")+1:]
+}
+
+// typeName describes a schema in a few words, like "array of Paste".
+func (s openAPISchema) typeName() string {
+	switch {
+	case s.Ref != "":
+		return refName(s.Ref)
+	case s.Items != nil:
+		return "array of " + s.Items.typeName()
+	case s.Format != "":
+		return fmt.Sprintf("%s (%s)", s.Type, s.Format)
+	}
+	return s.Type
+}
+
+// docsField, docsBody, docsOperation and docsSchema are what the docs page
+// shows, with every reference resolved.
+type docsField struct {
+	Name        string
+	In          string
+	Type        string
+	Required    bool
+	Description string
+}
+
+type docsBody struct {
+	Code        string
+	Description string
+	MediaTypes  []string
+	Schema      string
+}
+
+type docsOperation struct {
+	Method      string
+	Path        string
+	Anchor      string
+	Summary     string
+	Description string
+	Parameters  []docsField
+	RequestBody []docsBody
+	Responses   []docsBody
+}
+
+type docsSection struct {
+	Tag         string
+	Description string
+	Operations  []docsOperation
+}
+
+type docsSchema struct {
+	Name        string
+	Description string
+	Fields      []docsField
+}
+
+func (spec openAPISpec) parameter(p openAPIParameter) docsField {
+	if p.Ref != "" {
+		p = spec.Components.Parameters[refName(p.Ref)]
+	}
+	return docsField{Name: p.Name, In: p.In, Required: p.Required, Description: p.Description}
+}
+
+// newDocsBody describes a request body or response by media type and schema.
+func newDocsBody(code, description string, content map[string]openAPIMedia) docsBody {
+	body := docsBody{Code: code, Description: description}
+	for mediaType := range content {
+		body.MediaTypes = append(body.MediaTypes, mediaType)
+	}
+	sort.Strings(body.MediaTypes)
+	for _, mediaType := range body.MediaTypes {
+		if mediaType == gin.MIMEJSON || body.Schema == "" {
+			body.Schema = content[mediaType].Schema.typeName()
+		}
+	}
+	return body
+}
+
+var docsMethodOrder = []string{"get", "post", "put", "delete"}
+
+// docsPage flattens the spec into sections by tag, in the order the tags are
+// declared, and the schemas by name.
+func (spec openAPISpec) docsPage() ([]docsSection, []docsSchema, error) {
+	paths := make([]string, 0, len(spec.Paths))
+	for path := range spec.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	sections := []docsSection{}
+	sectionIndex := map[string]int{}
+	for _, tag := range spec.Tags {
+		sectionIndex[tag.Name] = len(sections)
+		sections = append(sections, docsSection{Tag: tag.Name, Description: tag.Description})
+	}
+	for _, path := range paths {
+		item := spec.Paths[path]
+		var shared []openAPIParameter
+		if raw, ok := item["parameters"]; ok {
+			err := json.Unmarshal(raw, &shared)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to parse parameters of %s: %v", path, err)
+			}
+		}
+		for _, method := range docsMethodOrder {
+			raw, ok := item[method]
+			if !ok {
+				continue
+			}
+			var op openAPIOperation
+			err := json.Unmarshal(raw, &op)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to parse %s %s: %v", method, path, err)
+			}
+			doc := docsOperation{
+				Method:      strings.ToUpper(method),
+				Path:        path,
+				Anchor:      method + strings.NewReplacer("/", "-", "{", "", "}", "", ".", "-").Replace(path),
+				Summary:     op.Summary,
+				Description: op.Description,
+			}
+			for _, p := range shared {
+				doc.Parameters = append(doc.Parameters, spec.parameter(p))
+			}
+			for _, p := range op.Parameters {
+				doc.Parameters = append(doc.Parameters, spec.parameter(p))
+			}
+			if op.RequestBody != nil {
+				body := *op.RequestBody
+				if body.Ref != "" {
+					body = spec.Components.RequestBodies[refName(body.Ref)]
+				}
+				doc.RequestBody = append(doc.RequestBody, newDocsBody("", "", body.Content))
+			}
+			codes := make([]string, 0, len(op.Responses))
+			for code := range op.Responses {
+				codes = append(codes, code)
+			}
+			sort.Strings(codes)
+			for _, code := range codes {
+				resp := op.Responses[code]
+				if resp.Ref != "" {
+					resp = spec.Components.Responses[refName(resp.Ref)]
+				}
+				doc.Responses = append(doc.Responses, newDocsBody(code, resp.Description, resp.Content))
+			}
+
+			tag := "other"
+			if len(op.Tags) > 0 {
+				tag = op.Tags[0]
+			}
+			i, ok := sectionIndex[tag]
+			if !ok {
+				i = len(sections)
+				sectionIndex[tag] = i
+				sections = append(sections, docsSection{Tag: tag})
+			}
+			sections[i].Operations = append(sections[i].Operations, doc)
+		}
+	}
+
+	names := make([]string, 0, len(spec.Components.Schemas))
+	for name := range spec.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	schemas := []docsSchema{}
+	for _, name := range names {
+		s := spec.Components.Schemas[name]
+		fields := make([]string, 0, len(s.Properties))
+		for field := range s.Properties {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		doc := docsSchema{Name: name, Description: s.Description}
+		for _, field := range fields {
+			p := s.Properties[field]
+			t := p.typeName()
+			if p.Nullable {
+				t += " or null"
+			}
+			if len(p.Enum) > 0 {
+				t += fmt.Sprintf(" %q", p.Enum)
+			}
+			doc.Fields = append(doc.Fields, docsField{Name: field, Type: t, Description: p.Description})
+		}
+		schemas = append(schemas, doc)
+	}
+	return sections, schemas, nil
+}
+
+// getOpenAPI handles GET /api/openapi.json
+func (h *WebHandler) getOpenAPI(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json; charset=utf-8", openAPIDocument)
+}
+
+// getAPIDocs handles GET /api/docs
+func (h *WebHandler) getAPIDocs(c *gin.Context) {
+	spec, err := loadOpenAPISpec()
+	if err != nil {
+		c.String(http.StatusInternalServerError, "%s\n", err)
+		return
+	}
+	sections, schemas, err := spec.docsPage()
+	if err != nil {
+		c.String(http.StatusInternalServerError, "%s\n", err)
+		return
+	}
+	c.HTML(http.StatusOK, "templates/apidocs.html", gin.H{
+		"title":       spec.Info.Title,
+		"version":     spec.Info.Version,
+		"description": spec.Info.Description,
+		"sections":    sections,
+		"schemas":     schemas,
+	})
+}
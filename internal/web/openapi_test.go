@@ -0,0 +1,218 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestHandler() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	// optional routes are on so they're checked against the spec too
+	NewWebHandler(&WebConfig{Host: "localhost", Port: "8080", Hastebin: true}, server)
+	return server
+}
+
+var pathParam = regexp.MustCompile(`:([A-Za-z]+)`)
+
+// pageRoutes are the pages browsers load and the assets they use, which the
+// spec leaves out. Every other route has to be in it.
+var pageRoutes = map[string]bool{
+	"GET /":                   true,
+	"GET /about":              true,
+	"GET /:pasteId":           true,
+	"POST /:pasteId":          true,
+	"GET /:pasteId/link":      true,
+	"GET /:pasteId/rev/:rev":  true,
+	"POST /:pasteId/rev/:rev": true,
+	"POST /:pasteId/edit":     true,
+	"GET /diff/:a/:b":         true,
+	"POST /diff/:a/:b":        true,
+	"GET /themes/:theme":      true,
+	"GET /static/*filepath":   true,
+	"HEAD /static/*filepath":  true,
+}
+
+// TestOpenAPIRoutes fails when a route other than a page is missing from the
+// spec or the spec describes a route that isn't registered.
+func TestOpenAPIRoutes(t *testing.T) {
+	routes := map[string]bool{}
+	registered := map[string]bool{}
+	for _, r := range newTestHandler().Routes() {
+		registered[r.Method+" "+r.Path] = true
+		if !pageRoutes[r.Method+" "+r.Path] {
+			routes[r.Method+" "+pathParam.ReplaceAllString(r.Path, "{$1}")] = true
+		}
+	}
+	for _, route := range sortedKeys(pageRoutes) {
+		if !registered[route] {
+			t.Errorf("page route %s isn't registered", route)
+		}
+	}
+
+	spec, err := loadOpenAPISpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	documented := map[string]bool{}
+	for path, item := range spec.Paths {
+		for method := range item {
+			if method == "parameters" {
+				continue
+			}
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+
+	for _, route := range sortedKeys(routes) {
+		if !documented[route] {
+			t.Errorf("route %s is missing from openapi.json", route)
+		}
+	}
+	for _, route := range sortedKeys(documented) {
+		if !routes[route] {
+			t.Errorf("openapi.json describes %s, which isn't a route", route)
+		}
+	}
+}
+
+// TestOpenAPISchemas fails when a schema in the spec doesn't have the same
+// fields as the type the handlers encode. Request bodies only need to be a
+// subset, since the handlers ignore fields they don't read.
+func TestOpenAPISchemas(t *testing.T) {
+	spec, err := loadOpenAPISpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exact := map[string]any{
+		"CreateResponse":   createResponse{},
+		"Paste":            pasteResponse{},
+		"PasteSummary":     PasteSummary{},
+		"PasteUpdate":      pasteUpdate{},
+		"File":             FileEntry{},
+		"Attachment":       AttachmentEntry{},
+		"Revision":         RevisionEntry{},
+		"Fork":             forkResponse{},
+		"Diff":             diffResponse{},
+		"Error":            errorResponse{},
+		"HastebinKey":      hastebinKey{},
+		"HastebinDocument": hastebinDocument{},
+	}
+	subset := map[string]any{
+		"NewPaste": PasteEntry{},
+	}
+
+	for name := range spec.Components.Schemas {
+		if exact[name] == nil && subset[name] == nil {
+			t.Errorf("schema %s isn't checked against a type", name)
+		}
+	}
+	check := func(name string, v any, wantAll bool) {
+		schema, ok := spec.Components.Schemas[name]
+		if !ok {
+			t.Errorf("schema %s is missing from openapi.json", name)
+			return
+		}
+		fields := jsonFields(reflect.TypeOf(v))
+		for property := range schema.Properties {
+			if !fields[property] {
+				t.Errorf("schema %s has %s, which %T doesn't", name, property, v)
+			}
+		}
+		if !wantAll {
+			return
+		}
+		for field := range fields {
+			if _, ok := schema.Properties[field]; !ok {
+				t.Errorf("schema %s is missing %s from %T", name, field, v)
+			}
+		}
+	}
+	for name, v := range exact {
+		check(name, v, true)
+	}
+	for name, v := range subset {
+		check(name, v, false)
+	}
+}
+
+// TestOpenAPIRefs fails when a reference in the spec points nowhere.
+func TestOpenAPIRefs(t *testing.T) {
+	var doc map[string]any
+	err := json.Unmarshal(openAPIDocument, &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			if ref, ok := v["$ref"].(string); ok {
+				var target any = doc
+				for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+					m, _ := target.(map[string]any)
+					target = m[part]
+				}
+				if target == nil {
+					t.Errorf("reference %s points nowhere", ref)
+				}
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []any:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(doc)
+}
+
+func TestAPIDocs(t *testing.T) {
+	server := newTestHandler()
+	for _, path := range []string{"/api/openapi.json", "/api/docs"} {
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("GET %s: got %d, want 200", path, rec.Code)
+		}
+	}
+}
+
+// jsonFields lists the names a type is encoded with.
+func jsonFields(t reflect.Type) map[string]bool {
+	fields := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = f.Name
+		}
+		fields[name] = true
+	}
+	return fields
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,135 @@
+package web
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/gin-gonic/gin"
+	"github.com/lcrownover/duckpaste/internal/audit"
+	"github.com/lcrownover/duckpaste/internal/db"
+)
+
+// Plain uploads make pasting from a terminal a one liner:
+//
+//	some-command | curl --data-binary @- https://server/
+//	curl -F file=@notes.txt https://server/?expire=1h
+//
+// The whole body is the paste, or with a multipart request the file part.
+// Options come as query parameters or headers and the answer is the URL of
+// the paste on a line of its own, errors included, so it reads well in a
+// terminal.
+
+// plainOption reads an option from the query, falling back to the header.
+func plainOption(c *gin.Context, query, header string) (string, bool) {
+	if value, ok := c.GetQuery(query); ok {
+		return value, true
+	}
+	if values, ok := c.Request.Header[http.CanonicalHeaderKey(header)]; ok && len(values) > 0 {
+		return values[0], true
+	}
+	return "", false
+}
+
+// parseFlag reads a yes or no option. A bare ?burn means yes.
+func parseFlag(value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "1", "true", "yes", "on":
+		return true, nil
+	case "0", "false", "no", "off":
+		return false, nil
+	}
+	return false, fmt.Errorf("expected yes or no, got %s", value)
+}
+
+// readPlainContent reads the paste from the file part of a multipart
+// request or from the raw body, returning the file name if there is one.
+func readPlainContent(c *gin.Context) (string, string, error) {
+	if c.ContentType() != gin.MIMEMultipartPOSTForm {
+		data, err := io.ReadAll(c.Request.Body)
+		return string(data), "", err
+	}
+	fh, err := c.FormFile("file")
+	if err == http.ErrMissingFile {
+		// -F file=<notes.txt sends the content as a plain field
+		return c.PostForm("file"), "", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return "", "", err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	return string(data), fh.Filename, err
+}
+
+// createPlainPaste handles POST / for plain uploads. It takes ?expire=
+// (X-Expire), ?burn (X-Burn), ?views= (X-Views) and ?lang= (X-Lang).
+func (h *WebHandler) createPlainPaste(c *gin.Context) {
+	content, filename, err := readPlainContent(c)
+	if isTooLarge(err) {
+		abortTooLarge(c, h.sizeLimits(c).MaxBodyBytes)
+		return
+	}
+	if err != nil {
+		abortWithError(c, http.StatusBadRequest, fmt.Sprintf("failed to read paste: %s", err))
+		return
+	}
+	if strings.TrimSpace(content) == "" {
+		abortWithError(c, http.StatusBadRequest, "please provide actual content")
+		return
+	}
+
+	paste := PasteEntry{Content: content}
+	if !checkContentSize(c, paste) {
+		return
+	}
+	if value, ok := plainOption(c, "burn", "X-Burn"); ok {
+		paste.DeleteOnRead, err = parseFlag(value)
+		if err != nil {
+			abortWithError(c, http.StatusBadRequest, fmt.Sprintf("invalid burn: %s", err))
+			return
+		}
+	}
+	if value, ok := plainOption(c, "views", "X-Views"); ok {
+		paste.MaxViews, err = strconv.Atoi(value)
+		if err != nil || paste.MaxViews < 0 {
+			abortWithError(c, http.StatusBadRequest, "views must be a number of reads, 0 for no limit")
+			return
+		}
+	}
+	language, _ := plainOption(c, "lang", "X-Lang")
+	paste.Language, err = normalizeLanguage(language)
+	if err != nil {
+		abortWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if paste.Language == "" && filename != "" {
+		if lexer := lexers.Match(filename); lexer != nil {
+			paste.Language = lexerName(lexer)
+		}
+	}
+	expiration, _ := plainOption(c, "expire", "X-Expire")
+	paste.Expires, err = resolveExpiry(expiration, 0, db.GetCurrentTime(), h.config.Expiration)
+	if err != nil {
+		abortWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	paste.owner = c.GetString(apiUserKey)
+
+	paste, err = createPasteEntry(paste, nil)
+	if err != nil {
+		recordEvent(c, audit.ActionCreate, paste.Id, audit.OutcomeFailure, err.Error())
+		abortWithError(c, http.StatusInternalServerError, fmt.Sprintf("failed to create paste entry: %s", err))
+		return
+	}
+	recordEvent(c, audit.ActionCreate, paste.Id, audit.OutcomeSuccess, "")
+	c.Header("X-Edit-Token", paste.EditToken)
+	c.String(http.StatusCreated, "%s\n", h.pasteURL(paste.Id))
+}
@@ -0,0 +1,93 @@
+package web
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lcrownover/duckpaste/internal/db"
+	"github.com/skip2/go-qrcode"
+)
+
+// QR codes of the paste URL, for moving a paste to a phone. They only
+// encode the URL, so fetching one never reads the paste itself, but the
+// paste still has to be one the client could open.
+
+const (
+	defaultQRSize int = 256
+	minQRSize     int = 64
+	maxQRSize     int = 1024
+)
+
+// getQR handles GET /:pasteId/qr, answering with a PNG or, with
+// ?format=svg, an SVG. ?size= sets the width in pixels.
+func (h *WebHandler) getQR(c *gin.Context) {
+	pasteID := c.Param("pasteId")
+	if !pasteIDPattern.MatchString(pasteID) {
+		c.String(http.StatusNotFound, "no paste found with id: %s\n", pasteID)
+		return
+	}
+	size := defaultQRSize
+	if s := c.Query("size"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < minQRSize || n > maxQRSize {
+			c.String(http.StatusBadRequest, "size must be between %d and %d\n", minQRSize, maxQRSize)
+			return
+		}
+		size = n
+	}
+	format := c.DefaultQuery("format", "png")
+	if format != "png" && format != "svg" {
+		c.String(http.StatusBadRequest, "format must be png or svg\n")
+		return
+	}
+
+	// reading the metadata doesn't count as a read, so burn after read
+	// pastes survive it
+	item, err := dbClient.ReadItemMetadata(db.ItemID(pasteID))
+	if err != nil || isExpired(*item) {
+		c.String(http.StatusNotFound, "no paste found with id: %s\n", pasteID)
+		return
+	}
+	if !pasteAllowsClient(c, NewPasteEntryFromDbItem(*item)) {
+		c.String(http.StatusForbidden, "this paste is not viewable from your network\n")
+		return
+	}
+
+	qr, err := qrcode.New(h.pasteURL(pasteID), qrcode.Medium)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "failed to make QR code: %s\n", err)
+		return
+	}
+	c.Header("Cache-Control", "private, max-age=3600")
+	if format == "svg" {
+		c.Data(http.StatusOK, "image/svg+xml", qrSVG(qr, size))
+		return
+	}
+	png, err := qr.PNG(size)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "failed to make QR code: %s\n", err)
+		return
+	}
+	c.Data(http.StatusOK, "image/png", png)
+}
+
+// qrSVG draws the QR code as one path of unit squares.
+func qrSVG(qr *qrcode.QRCode, size int) []byte {
+	bitmap := qr.Bitmap()
+	n := len(bitmap)
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, size, size, n, n)
+	buf.WriteString(`<rect width="100%" height="100%" fill="#ffffff"/><path fill="#000000" d="`)
+	for y, row := range bitmap {
+		for x, on := range row {
+			if on {
+				fmt.Fprintf(&buf, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+	return buf.Bytes()
+}
@@ -0,0 +1,116 @@
+package web
+
+import (
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Raw and download endpoints answer in plain text, errors included, so they
+// read well from curl. The content is decoded straight into the response.
+
+// getRaw serves the paste content, or just some of its lines with
+// ?lines=12-40.
+func (h *WebHandler) getRaw(c *gin.Context) {
+	var lines lineRange
+	var err error
+	if c.Query("lines") != "" {
+		lines, err = parseLineRange(c.Query("lines"))
+		if err != nil {
+			c.String(http.StatusBadRequest, err.Error()+"\n")
+			return
+		}
+	}
+	// the range is checked before the read is counted, so asking for lines
+	// past the end doesn't burn the paste
+	paste, err := checkPaste(c, c.Param("pasteId"), requestPassword(c))
+	if err != nil {
+		rawReadError(c, err)
+		return
+	}
+	c.Header("X-Content-Type-Options", "nosniff")
+	if lines.Start == 0 {
+		paste, err = consumePaste(c, paste)
+		if err != nil {
+			rawReadError(c, err)
+			return
+		}
+		streamContent(c, paste, nil)
+		return
+	}
+
+	br := contentReader(paste.Content)
+	skipped, err := skipLines(br, lines.Start-1)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "failed to decode content of paste\n")
+		return
+	}
+	if skipped < lines.Start-1 || atEOF(br) {
+		c.String(http.StatusRequestedRangeNotSatisfiable, fmt.Sprintf("line %d is past the end of the paste, it has %d lines\n", lines.Start, skipped))
+		return
+	}
+	paste, err = consumePaste(c, paste)
+	if err != nil {
+		rawReadError(c, err)
+		return
+	}
+	c.Status(http.StatusOK)
+	c.Header("Content-Type", rawMimeType(paste))
+	_, err = copyLines(c.Writer, br, lines.End-lines.Start+1)
+	if err != nil {
+		slog.Error("failed to stream paste: "+err.Error(), "id", paste.Id, "source", "getRaw")
+	}
+}
+
+func (h *WebHandler) getDownload(c *gin.Context) {
+	paste, ok := h.readRawPaste(c)
+	if !ok {
+		return
+	}
+	filename := paste.Id + languageExtension(paste.Language)
+	if paste.ContentType == contentTypeBinary {
+		filename = paste.Id + ".bin"
+	}
+	c.Header("X-Content-Type-Options", "nosniff")
+	streamContent(c, paste, map[string]string{
+		"Content-Disposition": mime.FormatMediaType("attachment", map[string]string{"filename": filename}),
+	})
+}
+
+// streamContent writes the whole paste content, decoding it on the way.
+func streamContent(c *gin.Context, paste PasteEntry, headers map[string]string) {
+	c.DataFromReader(http.StatusOK, decodedSize(paste.Content), rawMimeType(paste), contentReader(paste.Content), headers)
+	if len(c.Errors) > 0 {
+		slog.Error("failed to stream paste: "+c.Errors.Last().Error(), "id", paste.Id, "source", "streamContent")
+	}
+}
+
+// rawMimeType is text for everything but binary pastes.
+func rawMimeType(paste PasteEntry) string {
+	if paste.ContentType == contentTypeBinary {
+		return "application/octet-stream"
+	}
+	return "text/plain; charset=utf-8"
+}
+
+// readRawPaste opens the paste, answering the request itself when it can't.
+func (h *WebHandler) readRawPaste(c *gin.Context) (PasteEntry, bool) {
+	paste, err := openPaste(c, c.Param("pasteId"))
+	if err != nil {
+		rawReadError(c, err)
+		return paste, false
+	}
+	return paste, true
+}
+
+// rawReadError answers a raw read of a paste that couldn't be opened.
+func rawReadError(c *gin.Context, err error) {
+	status := readStatus(err)
+	if status == http.StatusUnauthorized {
+		c.Header("WWW-Authenticate", `Basic realm="duckpaste"`)
+	}
+	c.String(status, err.Error()+"\n")
+}
@@ -0,0 +1,198 @@
+package web
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lcrownover/duckpaste/internal/audit"
+	"github.com/lcrownover/duckpaste/internal/db"
+)
+
+// Pastes can be edited by whoever holds the edit token handed out when the
+// paste was created. Every edit keeps the previous content as a revision.
+// Expiry still counts from the original creation.
+
+const editCookiePrefix string = "duckpaste_edit_"
+
+// RevisionEntry describes one version of a paste's content.
+type RevisionEntry struct {
+	Number  int       `json:"number"`
+	Created time.Time `json:"created"`
+	URL     string    `json:"url"`
+}
+
+func NewRevisionEntries(item db.Item) []RevisionEntry {
+	entries := []RevisionEntry{}
+	for i, r := range item.Revisions {
+		entries = append(entries, RevisionEntry{
+			Number:  i + 1,
+			Created: r.Created,
+			URL:     fmt.Sprintf("/%s/rev/%d", item.Id, i+1),
+		})
+	}
+	current := item.Updated
+	if current.IsZero() {
+		current = item.Created
+	}
+	return append(entries, RevisionEntry{
+		Number:  len(item.Revisions) + 1,
+		Created: current,
+		URL:     fmt.Sprintf("/%s/rev/%d", item.Id, len(item.Revisions)+1),
+	})
+}
+
+func newEditToken() (string, error) {
+	b := make([]byte, 24)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate edit token: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hashEditToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func editTokenMatches(item db.Item, token string) bool {
+	if item.EditToken == "" || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(hashEditToken(token)), []byte(item.EditToken)) == 1
+}
+
+// requestEditToken finds the edit token in the X-Edit-Token header, the
+// editToken form field, or the cookie set for the creator's browser.
+func requestEditToken(c *gin.Context, pasteID string) string {
+	if token := c.GetHeader("X-Edit-Token"); token != "" {
+		return token
+	}
+	if token := c.PostForm("editToken"); token != "" {
+		return token
+	}
+	token, _ := c.Cookie(editCookiePrefix + pasteID)
+	return token
+}
+
+// giveEditToken hands the edit token of a new paste to the client: in a
+// header for API clients and as a cookie scoped to the paste for browsers.
+func giveEditToken(c *gin.Context, paste PasteEntry) {
+	c.Header("X-Edit-Token", paste.EditToken)
+	// pastes that never expire keep the cookie for a year
+	lifetime := 365 * 24 * time.Hour
+	if paste.Expires != nil {
+		lifetime = time.Until(*paste.Expires)
+	}
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(editCookiePrefix+paste.Id, paste.EditToken, int(lifetime.Seconds()), "/"+paste.Id, "", c.Request.TLS != nil, true)
+}
+
+type pasteUpdate struct {
+	Content  string `json:"content" form:"pasteContent"`
+	Language string `json:"language" form:"pasteLanguage"`
+}
+
+// updatePaste validates and applies an edit, answering the request itself
+// when it fails.
+func (h *WebHandler) updatePaste(c *gin.Context) (PasteEntry, bool) {
+	pasteID := c.Param("pasteId")
+
+	var update pasteUpdate
+	err := c.ShouldBind(&update)
+	if isTooLarge(err) {
+		abortTooLarge(c, h.sizeLimits(c).MaxBodyBytes)
+		return PasteEntry{}, false
+	}
+	if err != nil {
+		abortWithError(c, http.StatusBadRequest, fmt.Sprintf("couldn't unmarshal payload: %s", err))
+		return PasteEntry{}, false
+	}
+	if strings.TrimSpace(update.Content) == "" {
+		abortWithError(c, http.StatusBadRequest, "please provide actual content")
+		return PasteEntry{}, false
+	}
+	if !checkContentSize(c, PasteEntry{Content: update.Content}) {
+		return PasteEntry{}, false
+	}
+	update.Language, err = normalizeLanguage(update.Language)
+	if err != nil {
+		abortWithError(c, http.StatusBadRequest, err.Error())
+		return PasteEntry{}, false
+	}
+
+	paste, err := editPasteEntry(pasteID, requestEditToken(c, pasteID), update)
+	if err != nil {
+		outcome := audit.OutcomeFailure
+		if readStatus(err) == http.StatusForbidden {
+			outcome = audit.OutcomeDenied
+		}
+		recordEvent(c, audit.ActionEdit, pasteID, outcome, err.Error())
+		abortWithError(c, readStatus(err), err.Error())
+		return PasteEntry{}, false
+	}
+	recordEvent(c, audit.ActionEdit, pasteID, audit.OutcomeSuccess, fmt.Sprintf("revision %d", len(paste.Revisions)))
+	return paste, true
+}
+
+// updatePasteApi handles PUT /api/paste/:pasteId
+func (h *WebHandler) updatePasteApi(c *gin.Context) {
+	paste, ok := h.updatePaste(c)
+	if !ok {
+		return
+	}
+	h.respondPasteEntry(c, http.StatusOK, paste)
+}
+
+// editPaste handles the edit form on the paste page
+func (h *WebHandler) editPaste(c *gin.Context) {
+	paste, ok := h.updatePaste(c)
+	if !ok {
+		return
+	}
+	c.Redirect(http.StatusFound, "/"+paste.Id)
+}
+
+// getRevision shows a specific version of the paste content.
+func (h *WebHandler) getRevision(c *gin.Context) {
+	pasteID := c.Param("pasteId")
+	paste, err := checkPaste(c, pasteID, requestPassword(c))
+	if err != nil {
+		h.pasteReadError(c, err)
+		return
+	}
+	// the revision is checked before the read is counted, so asking for
+	// one that doesn't exist doesn't burn the paste
+	n, err := strconv.Atoi(c.Param("rev"))
+	if !paste.ShortLink && (err != nil || n < 1 || n > len(paste.Revisions)) {
+		c.HTML(http.StatusNotFound, "templates/notfound.html", nil)
+		return
+	}
+	if !paste.ShortLink && n < len(paste.Revisions) {
+		r := paste.revisions[n-1]
+		paste.Content = string(r.Content)
+		paste.Language = r.Language
+		paste.ContentType = ""
+	}
+	if !paste.ShortLink && !pageInRange(c, paste) {
+		return
+	}
+	paste, err = consumePaste(c, paste)
+	if err != nil {
+		h.pasteReadError(c, err)
+		return
+	}
+	if paste.ShortLink {
+		h.followLink(c, paste)
+		return
+	}
+	h.renderPaste(c, paste, n)
+}
@@ -0,0 +1,92 @@
+package web
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Large pastes aren't decoded into memory whole. Raw reads decode the stored
+// base64 straight into the response, and the paste page shows a page of
+// lines at a time.
+
+const (
+	// pastes bigger than this are shown a page at a time
+	maxInlineBytes int64 = 256 << 10
+	pageLines      int   = 2000
+)
+
+// contentReader decodes stored content as it's read.
+func contentReader(content string) *bufio.Reader {
+	return bufio.NewReader(base64.NewDecoder(base64.StdEncoding, strings.NewReader(content)))
+}
+
+// decodedSize is the size of stored content once decoded.
+func decodedSize(content string) int64 {
+	padding := len(content) - len(strings.TrimRight(content, "="))
+	return int64(len(content)/4*3 - padding)
+}
+
+// contentPage is one page of a large paste.
+type contentPage struct {
+	Number     int
+	Pages      int
+	FirstLine  int
+	LastLine   int
+	TotalLines int
+	Size       string
+	PrevURL    string
+	NextURL    string
+	FullURL    string
+}
+
+// readPage decodes the lines of the given page of the content, counting the
+// lines of the rest without keeping them.
+func readPage(content string, number int) (string, contentPage, error) {
+	br := contentReader(content)
+	page := contentPage{
+		Number:    number,
+		FirstLine: (number-1)*pageLines + 1,
+		Size:      formatBytes(decodedSize(content)),
+	}
+	skipped, err := skipLines(br, page.FirstLine-1)
+	if err != nil {
+		return "", page, fmt.Errorf("failed to decode content: %v", err)
+	}
+	var buf bytes.Buffer
+	copied, err := copyLines(&buf, br, pageLines)
+	if err != nil {
+		return "", page, fmt.Errorf("failed to decode content: %v", err)
+	}
+	rest, err := skipLines(br, math.MaxInt)
+	if err != nil {
+		return "", page, fmt.Errorf("failed to decode content: %v", err)
+	}
+	page.TotalLines = skipped + copied + rest
+	page.LastLine = page.FirstLine + copied - 1
+	page.Pages = max(1, (page.TotalLines+pageLines-1)/pageLines)
+	return buf.String(), page, nil
+}
+
+// pageLinks fills in the links to the neighbouring pages and the full view.
+func (p *contentPage) pageLinks(u *url.URL) {
+	link := func(key, value string) string {
+		q := u.Query()
+		q.Del("page")
+		q.Del("full")
+		q.Set(key, value)
+		return u.Path + "?" + q.Encode()
+	}
+	if p.Number > 1 {
+		p.PrevURL = link("page", strconv.Itoa(p.Number-1))
+	}
+	if p.Number < p.Pages {
+		p.NextURL = link("page", strconv.Itoa(p.Number+1))
+	}
+	p.FullURL = link("full", "1")
+}